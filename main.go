@@ -9,6 +9,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/drone-plugins/drone-npm/plugin"
 	"github.com/drone-plugins/drone-plugin-lib/errors"
@@ -138,5 +139,244 @@ func settingsFlags(settings *plugin.Settings) []cli.Flag {
 			EnvVars:     []string{"PLUGIN_SKIP_REGISTRY_VALIDATION"},
 			Destination: &settings.SkipRegistryValidation,
 		},
+		&cli.BoolFlag{
+			Name:        "http-publish",
+			Usage:       "publish the package directly over HTTP instead of using the npm CLI",
+			EnvVars:     []string{"PLUGIN_HTTP_PUBLISH"},
+			Destination: &settings.HTTPPublish,
+		},
+		&cli.BoolFlag{
+			Name:        "workspaces",
+			Usage:       "publish every public package of the npm workspaces in the folder",
+			EnvVars:     []string{"PLUGIN_WORKSPACES"},
+			Destination: &settings.Workspaces,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "run every step without publishing the package",
+			EnvVars:     []string{"PLUGIN_DRY_RUN"},
+			Destination: &settings.DryRun,
+		},
+		&cli.StringFlag{
+			Name:        "prerelease-tags",
+			Usage:       "JSON object mapping prerelease identifiers to the dist-tag to publish with",
+			EnvVars:     []string{"PLUGIN_PRERELEASE_TAGS"},
+			Destination: &settings.PrereleaseTags,
+		},
+		&cli.BoolFlag{
+			Name:        "disallow-prerelease-latest",
+			Usage:       "fail instead of publishing a prerelease version with the latest tag",
+			EnvVars:     []string{"PLUGIN_DISALLOW_PRERELEASE_LATEST"},
+			Destination: &settings.DisallowPrereleaseLatest,
+		},
+		&cli.BoolFlag{
+			Name:        "version-from-tag",
+			Usage:       "verify the package version matches the version of the git tag",
+			EnvVars:     []string{"PLUGIN_VERSION_FROM_TAG"},
+			Destination: &settings.VersionFromTag,
+		},
+		&cli.BoolFlag{
+			Name:        "rewrite-version",
+			Usage:       "publish with the version of the git tag instead of failing when it does not match the package version",
+			EnvVars:     []string{"PLUGIN_REWRITE_VERSION"},
+			Destination: &settings.RewriteVersion,
+		},
+		&cli.BoolFlag{
+			Name:        "snapshot",
+			Usage:       "publish a unique prerelease version built from the build number and commit",
+			EnvVars:     []string{"PLUGIN_SNAPSHOT"},
+			Destination: &settings.Snapshot,
+		},
+		&cli.StringFlag{
+			Name:        "snapshot-tag",
+			Usage:       "dist-tag and prerelease identifier of snapshot versions",
+			Value:       "canary",
+			EnvVars:     []string{"PLUGIN_SNAPSHOT_TAG"},
+			Destination: &settings.SnapshotTag,
+		},
+		&cli.StringFlag{
+			Name:        "scopes",
+			Usage:       "JSON object mapping scopes to their registry and credentials",
+			EnvVars:     []string{"PLUGIN_SCOPES"},
+			Destination: &settings.Scopes,
+		},
+		&cli.BoolFlag{
+			Name:        "merge-npmrc",
+			Usage:       "merge the settings of the existing npmrc of the user into the generated npmrc",
+			EnvVars:     []string{"PLUGIN_MERGE_NPMRC"},
+			Destination: &settings.MergeNpmrc,
+		},
+		&cli.StringFlag{
+			Name:        "client",
+			Usage:       "client used to publish the package, npm, pnpm or yarn",
+			Value:       "npm",
+			EnvVars:     []string{"PLUGIN_CLIENT"},
+			Destination: &settings.Client,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-token",
+			Usage:       "OIDC ID token exchanged for a short-lived token publishing the package",
+			EnvVars:     []string{"PLUGIN_OIDC_TOKEN", "NPM_ID_TOKEN"},
+			Destination: &settings.OIDCToken,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-exchange-url",
+			Usage:       "registry exchanging the OIDC ID token, defaults to the registry",
+			EnvVars:     []string{"PLUGIN_OIDC_EXCHANGE_URL"},
+			Destination: &settings.OIDCExchangeURL,
+		},
+		&cli.StringFlag{
+			Name:        "ca-cert",
+			Usage:       "PEM encoded CA certificate, or the path of one, trusted in addition to the system certificates",
+			EnvVars:     []string{"PLUGIN_CA_CERT"},
+			Destination: &settings.CACert,
+		},
+		&cli.StringFlag{
+			Name:        "proxy",
+			Usage:       "proxy for requests to the registry",
+			EnvVars:     []string{"PLUGIN_PROXY"},
+			Destination: &settings.Proxy,
+		},
+		&cli.StringFlag{
+			Name:        "https-proxy",
+			Usage:       "proxy for https requests to the registry, defaults to the proxy",
+			EnvVars:     []string{"PLUGIN_HTTPS_PROXY"},
+			Destination: &settings.HTTPSProxy,
+		},
+		&cli.StringFlag{
+			Name:        "noproxy",
+			Usage:       "comma separated hosts requested without the proxy",
+			EnvVars:     []string{"PLUGIN_NOPROXY"},
+			Destination: &settings.NoProxy,
+		},
+		&cli.StringFlag{
+			Name:        "client-cert",
+			Usage:       "PEM encoded client certificate, or the path of one, presented to the registry",
+			EnvVars:     []string{"PLUGIN_CLIENT_CERT"},
+			Destination: &settings.ClientCert,
+		},
+		&cli.StringFlag{
+			Name:        "client-key",
+			Usage:       "PEM encoded key of the client certificate, or the path of one",
+			EnvVars:     []string{"PLUGIN_CLIENT_KEY"},
+			Destination: &settings.ClientKey,
+		},
+		&cli.IntFlag{
+			Name:        "retries",
+			Usage:       "number of times a failed whoami, version lookup or publish is retried",
+			EnvVars:     []string{"PLUGIN_RETRIES"},
+			Destination: &settings.Retries,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-delay",
+			Usage:       "delay before the first retry, doubled for each following one",
+			Value:       2 * time.Second,
+			EnvVars:     []string{"PLUGIN_RETRY_DELAY"},
+			Destination: &settings.RetryDelay,
+		},
+		&cli.BoolFlag{
+			Name:        "verify-publish",
+			Usage:       "wait until the registry serves the published version",
+			EnvVars:     []string{"PLUGIN_VERIFY_PUBLISH"},
+			Destination: &settings.VerifyPublish,
+		},
+		&cli.DurationFlag{
+			Name:        "verify-timeout",
+			Usage:       "how long to wait for the registry to serve the published version",
+			Value:       5 * time.Minute,
+			EnvVars:     []string{"PLUGIN_VERIFY_TIMEOUT"},
+			Destination: &settings.VerifyTimeout,
+		},
+		&cli.StringFlag{
+			Name:        "action",
+			Usage:       "action to run, publish, dist-tag, deprecate, undeprecate or unpublish",
+			Value:       "publish",
+			EnvVars:     []string{"PLUGIN_ACTION"},
+			Destination: &settings.Action,
+		},
+		&cli.StringFlag{
+			Name:        "add-dist-tags",
+			Usage:       "comma separated dist-tags pointed at the version by the dist-tag action",
+			EnvVars:     []string{"PLUGIN_ADD_DIST_TAGS"},
+			Destination: &settings.AddDistTags,
+		},
+		&cli.StringFlag{
+			Name:        "remove-dist-tags",
+			Usage:       "comma separated dist-tags removed by the dist-tag action",
+			EnvVars:     []string{"PLUGIN_REMOVE_DIST_TAGS"},
+			Destination: &settings.RemoveDistTags,
+		},
+		&cli.StringFlag{
+			Name:        "dist-tag-version",
+			Usage:       "version the dist-tag action points the dist-tags at, defaults to the package version",
+			EnvVars:     []string{"PLUGIN_DIST_TAG_VERSION"},
+			Destination: &settings.DistTagVersion,
+		},
+		&cli.StringFlag{
+			Name:        "deprecate-range",
+			Usage:       "semver range of the versions the deprecate and undeprecate actions update",
+			EnvVars:     []string{"PLUGIN_DEPRECATE_RANGE"},
+			Destination: &settings.DeprecateRange,
+		},
+		&cli.StringFlag{
+			Name:        "deprecate-message",
+			Usage:       "deprecation message set by the deprecate action",
+			EnvVars:     []string{"PLUGIN_DEPRECATE_MESSAGE"},
+			Destination: &settings.DeprecateMessage,
+		},
+		&cli.StringFlag{
+			Name:        "unpublish-version",
+			Usage:       "version removed by the unpublish action, defaults to the package version",
+			EnvVars:     []string{"PLUGIN_UNPUBLISH_VERSION"},
+			Destination: &settings.UnpublishVersion,
+		},
+		&cli.BoolFlag{
+			Name:        "unpublish-force",
+			Usage:       "allow the unpublish action to remove the whole package when the version is its only version",
+			EnvVars:     []string{"PLUGIN_UNPUBLISH_FORCE"},
+			Destination: &settings.UnpublishForce,
+		},
+		&cli.BoolFlag{
+			Name:        "restore-latest",
+			Usage:       "move the latest dist-tag back to the previous stable version after unpublishing it",
+			EnvVars:     []string{"PLUGIN_RESTORE_LATEST"},
+			Destination: &settings.RestoreLatest,
+		},
+		&cli.StringFlag{
+			Name:        "tarball",
+			Usage:       "path or glob of a prebuilt tarball to publish instead of the folder",
+			EnvVars:     []string{"PLUGIN_TARBALL"},
+			Destination: &settings.Tarball,
+		},
+		&cli.StringFlag{
+			Name:        "max-unpacked-size",
+			Usage:       "maximum unpacked size of the package, such as 10MB",
+			EnvVars:     []string{"PLUGIN_MAX_UNPACKED_SIZE"},
+			Destination: &settings.MaxUnpackedSize,
+		},
+		&cli.IntFlag{
+			Name:        "max-files",
+			Usage:       "maximum number of files in the package",
+			EnvVars:     []string{"PLUGIN_MAX_FILES"},
+			Destination: &settings.MaxFiles,
+		},
+		&cli.StringFlag{
+			Name:        "forbidden-files",
+			Usage:       "comma separated glob patterns of files the package must not include",
+			EnvVars:     []string{"PLUGIN_FORBIDDEN_FILES"},
+			Destination: &settings.ForbiddenFiles,
+		},
+		&cli.StringFlag{
+			Name:        "required-files",
+			Usage:       "comma separated glob patterns of files the package must include",
+			EnvVars:     []string{"PLUGIN_REQUIRED_FILES"},
+			Destination: &settings.RequiredFiles,
+		},
+		&cli.BoolFlag{
+			Name:        "require-entry-points",
+			Usage:       "require the main and types entry points of package.json to be included",
+			EnvVars:     []string{"PLUGIN_REQUIRE_ENTRY_POINTS"},
+			Destination: &settings.RequireEntryPoints,
+		},
 	}
 }
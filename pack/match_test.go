@@ -0,0 +1,40 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package pack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.js", "index.js", true},
+		{"*.js", "lib/index.js", false},
+		{"**/*.js", "index.js", true},
+		{"**/*.js", "lib/deep/index.js", true},
+		{"lib/**", "lib/deep/index.js", true},
+		{"lib/**", "src/index.js", false},
+		{"**/.env", ".env", true},
+		{"**/.env", "config/.env", true},
+		{"**/.env", "config/.env.example", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file/.txt", false},
+		{"[abc].md", "b.md", true},
+		{"[!abc].md", "b.md", false},
+		{"a.b", "axb", false},
+		{"[unterminated", "[unterminated", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, Match(test.pattern, test.name), "%s against %s", test.pattern, test.name)
+	}
+}
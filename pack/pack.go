@@ -0,0 +1,489 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+// Package pack creates npm package tarballs without requiring the npm CLI.
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// Tarball is a packed npm package.
+	Tarball struct {
+		Name         string
+		Version      string
+		Manifest     []byte
+		Files        []File
+		UnpackedSize int64
+		Shasum       string
+		Integrity    string
+		Data         []byte
+	}
+
+	// File is a single entry within a Tarball.
+	File struct {
+		Path string
+		Size int64
+	}
+
+	// manifest holds the package.json fields that influence packing.
+	manifest struct {
+		Name    string          `json:"name"`
+		Version string          `json:"version"`
+		Main    string          `json:"main"`
+		Browser json.RawMessage `json:"browser"`
+		Bin     json.RawMessage `json:"bin"`
+		Files   []string        `json:"files"`
+	}
+)
+
+// mtime is the modification time npm uses for every tarball entry so that
+// packing the same contents twice produces the same bytes.
+var mtime = time.Date(1985, time.October, 26, 8, 15, 0, 0, time.UTC)
+
+// alwaysIgnored lists the patterns npm never includes in a package.
+var alwaysIgnored = []string{
+	".npmignore",
+	".gitignore",
+	".git",
+	".svn",
+	".hg",
+	"CVS",
+	".lock-wscript",
+	".wafpickle-*",
+	".*.swp",
+	".DS_Store",
+	"._*",
+	"npm-debug.log",
+	".npmrc",
+	"node_modules",
+	"config.gypi",
+	"*.orig",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+}
+
+// alwaysIncluded lists the lowercase name prefixes of root files npm always
+// includes in a package.
+var alwaysIncluded = []string{
+	"readme",
+	"license",
+	"licence",
+	"copying",
+}
+
+// requiredFiles lists the files npm packs whatever the files field and ignore
+// files say: package.json, the shrinkwrap, the main and browser entry points
+// and the bin targets.
+func (m *manifest) requiredFiles() map[string]bool {
+	files := map[string]bool{
+		"package.json":        true,
+		"npm-shrinkwrap.json": true,
+	}
+	add := func(entry string) {
+		if entry = CleanPath(entry); entry != "" {
+			files[entry] = true
+		}
+	}
+
+	add(m.Main)
+
+	// browser can also be a map of replacements, which npm doesn't pack
+	browser := ""
+	if json.Unmarshal(m.Browser, &browser) == nil {
+		add(browser)
+	}
+
+	bin := ""
+	bins := map[string]string{}
+	if json.Unmarshal(m.Bin, &bin) == nil {
+		add(bin)
+	} else if json.Unmarshal(m.Bin, &bins) == nil {
+		for _, target := range bins {
+			add(target)
+		}
+	}
+
+	return files
+}
+
+// Pack creates a tarball from the package in the given folder using the same
+// file selection rules as npm: the "files" field of package.json when present,
+// otherwise everything not excluded by .npmignore (or .gitignore).
+func Pack(folder string) (*Tarball, error) {
+	data, err := os.ReadFile(filepath.Join(folder, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read package.json: %w", err)
+	}
+
+	m := manifest{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("could not parse package.json: %w", err)
+	}
+
+	files, err := selectFiles(folder, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+
+	tarball := &Tarball{
+		Name:     m.Name,
+		Version:  m.Version,
+		Manifest: data,
+	}
+
+	for _, file := range files {
+		size, err := addFile(tw, folder, file)
+		if err != nil {
+			return nil, err
+		}
+
+		tarball.Files = append(tarball.Files, File{Path: file, Size: size})
+		tarball.UnpackedSize += size
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	tarball.setData(buf.Bytes())
+
+	return tarball, nil
+}
+
+// setData stores the tarball bytes along with their checksums.
+func (t *Tarball) setData(data []byte) {
+	sha1Sum := sha1.Sum(data) //nolint:gosec
+	sha512Sum := sha512.Sum512(data)
+
+	t.Data = data
+	t.Shasum = hex.EncodeToString(sha1Sum[:])
+	t.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:])
+}
+
+// addFile writes the file to the tarball under the package/ prefix.
+func addFile(tw *tar.Writer, folder, file string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(folder, filepath.FromSlash(file)))
+	if err != nil {
+		return 0, fmt.Errorf("could not read %s: %w", file, err)
+	}
+
+	info, err := os.Stat(filepath.Join(folder, filepath.FromSlash(file)))
+	if err != nil {
+		return 0, err
+	}
+
+	var mode int64 = 0o644
+	if info.Mode()&0o111 != 0 {
+		mode = 0o755
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join("package", file),
+		Mode:     mode,
+		Size:     int64(len(data)),
+		ModTime:  mtime,
+		Format:   tar.FormatUSTAR,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return 0, err
+	}
+
+	return int64(len(data)), nil
+}
+
+// selectFiles returns the sorted slash separated paths of the files to pack.
+func selectFiles(folder string, m *manifest) ([]string, error) {
+	ignores := &ignoreFiles{
+		folder:   folder,
+		skipRoot: len(m.Files) > 0,
+		rules:    map[string][]ignoreRule{},
+	}
+	required := m.requiredFiles()
+
+	var files []string
+	err := filepath.WalkDir(folder, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(folder, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if isAlwaysIgnored(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rules, err := ignores.forPath(rel)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if ignored(rules, rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if isIncluded(m, required, rules, rel) {
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// isIncluded determines whether the regular file at rel should be packed.
+func isIncluded(m *manifest, required map[string]bool, rules []ignoreRule, rel string) bool {
+	if required[rel] {
+		return true
+	}
+
+	if !strings.Contains(rel, "/") {
+		lower := strings.ToLower(rel)
+		for _, prefix := range alwaysIncluded {
+			if strings.HasPrefix(lower, prefix) {
+				return true
+			}
+		}
+	}
+
+	if ignored(rules, rel, false) {
+		return false
+	}
+
+	if len(m.Files) == 0 {
+		return true
+	}
+
+	return matchesFiles(m.Files, rel)
+}
+
+// matchesFiles applies the entries of the files field in order, the last
+// matching entry wins. An entry matches the file itself or any directory
+// containing it and entries starting with "!" exclude what they match.
+func matchesFiles(entries []string, rel string) bool {
+	result := false
+
+	for _, entry := range entries {
+		negate := strings.HasPrefix(entry, "!")
+		entry = CleanPath(strings.TrimPrefix(entry, "!"))
+		if entry == "" {
+			continue
+		}
+
+		if Match(entry, rel) {
+			result = !negate
+			continue
+		}
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			if Match(entry, dir) {
+				result = !negate
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// CleanPath normalizes a path from package.json to a slash separated path
+// relative to the package root, or returns an empty path for the root.
+func CleanPath(entry string) string {
+	if entry == "" {
+		return ""
+	}
+
+	entry = path.Clean(strings.TrimPrefix(filepath.ToSlash(entry), "/"))
+	if entry == "." {
+		return ""
+	}
+
+	return entry
+}
+
+func isAlwaysIgnored(name string) bool {
+	for _, pattern := range alwaysIgnored {
+		if Match(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoreRule is a single line of a .npmignore or .gitignore file, matching
+// paths below the directory of the file.
+type ignoreRule struct {
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreFiles reads the ignore files of the directories in a package. The
+// ignore file at the root is left out when the files field selects the files,
+// as npm only applies the ones in subdirectories then.
+type ignoreFiles struct {
+	folder   string
+	skipRoot bool
+	rules    map[string][]ignoreRule
+}
+
+// forPath returns the rules of the directories containing rel, starting at
+// the root so the rules of deeper directories take precedence.
+func (i *ignoreFiles) forPath(rel string) ([]ignoreRule, error) {
+	var dirs []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	if !i.skipRoot {
+		dirs = append([]string{""}, dirs...)
+	}
+
+	var rules []ignoreRule
+	for _, dir := range dirs {
+		dirRules, found := i.rules[dir]
+		if !found {
+			var err error
+			if dirRules, err = readIgnoreFile(i.folder, dir); err != nil {
+				return nil, err
+			}
+			i.rules[dir] = dirRules
+		}
+		rules = append(rules, dirRules...)
+	}
+
+	return rules, nil
+}
+
+// readIgnoreFile parses the .npmignore in the directory of the folder,
+// falling back to the .gitignore like npm does.
+func readIgnoreFile(folder, dir string) ([]ignoreRule, error) {
+	for _, name := range []string{".npmignore", ".gitignore"} {
+		data, err := os.ReadFile(filepath.Join(folder, filepath.FromSlash(dir), name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", path.Join(dir, name), err)
+		}
+
+		rules := parseIgnore(string(data))
+		for i := range rules {
+			rules[i].base = dir
+		}
+
+		return rules, nil
+	}
+
+	return nil, nil
+}
+
+func parseIgnore(contents string) []ignoreRule {
+	var rules []ignoreRule
+
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		// Patterns without a slash match at any depth
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// ignored applies the rules in order, the last matching rule wins. Rules only
+// match paths below their directory, relative to it.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+
+		if Match(rule.pattern, target) {
+			result = !rule.negate
+		}
+	}
+
+	return result
+}
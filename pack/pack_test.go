@@ -0,0 +1,227 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func tarEntries(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func paths(files []File) []string {
+	var result []string
+	for _, file := range files {
+		result = append(result, file.Path)
+	}
+	return result
+}
+
+func TestPackWithFilesField(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json":          `{"name": "my-package", "version": "1.0.0", "main": "index.js", "files": ["lib", "types/*.d.ts"]}`,
+		"index.js":              "module.exports = {}",
+		"README.md":             "# my-package",
+		"LICENSE":               "MIT",
+		"lib/util.js":           "util",
+		"lib/deep/more.js":      "more",
+		"types/index.d.ts":      "types",
+		"types/index.test.ts":   "test",
+		"test/index.test.js":    "test",
+		"node_modules/x/x.js":   "dependency",
+		".npmrc":                "//registry.npmjs.org/:_authToken=secret",
+		"lib/node_modules/y.js": "nested dependency",
+	})
+
+	tarball, err := Pack(dir)
+	if assert.Nil(t, err) {
+		expected := []string{
+			"LICENSE",
+			"README.md",
+			"index.js",
+			"lib/deep/more.js",
+			"lib/util.js",
+			"package.json",
+			"types/index.d.ts",
+		}
+		assert.Equal(t, expected, paths(tarball.Files))
+		assert.Equal(t, "my-package", tarball.Name)
+		assert.Equal(t, "1.0.0", tarball.Version)
+		assert.Contains(t, tarball.Integrity, "sha512-")
+		assert.Len(t, tarball.Shasum, 40)
+		assert.Contains(t, tarEntries(t, tarball.Data), "package/lib/deep/more.js")
+	}
+}
+
+func TestPackWithIgnoreFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json":     `{"name": "my-package", "version": "1.0.0"}`,
+		".npmignore":       "# comment\ntest/\n*.log\n!keep.log\n/fixtures",
+		".gitignore":       "dist",
+		"index.js":         "index",
+		"dist/index.js":    "dist",
+		"test/index.js":    "test",
+		"src/test/x.js":    "nested test",
+		"debug.log":        "log",
+		"keep.log":         "log",
+		"fixtures/big.bin": "big",
+		"src/fixtures/a":   "not anchored",
+		".git/HEAD":        "ref",
+	})
+
+	tarball, err := Pack(dir)
+	if assert.Nil(t, err) {
+		expected := []string{
+			"dist/index.js",
+			"index.js",
+			"keep.log",
+			"package.json",
+			"src/fixtures/a",
+		}
+		assert.Equal(t, expected, paths(tarball.Files))
+	}
+}
+
+func TestPackWithFilesNegation(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json":         `{"name": "my-package", "version": "1.0.0", "files": ["dist", "!dist/*.test.js", "lib"]}`,
+		"dist/a.js":            "a",
+		"dist/a.test.js":       "test",
+		"dist/nested/b.js":     "b",
+		"lib/.npmignore":       "*.map\nfixtures/",
+		"lib/index.js":         "index",
+		"lib/index.js.map":     "map",
+		"lib/fixtures/data.js": "fixture",
+		"lib/util/x.js.map":    "map",
+	})
+
+	tarball, err := Pack(dir)
+	if assert.Nil(t, err) {
+		expected := []string{
+			"dist/a.js",
+			"dist/nested/b.js",
+			"lib/index.js",
+			"package.json",
+		}
+		assert.Equal(t, expected, paths(tarball.Files))
+	}
+}
+
+func TestPackRequiredFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json":        `{"name": "my-package", "version": "1.0.0", "files": ["lib"], "bin": {"x": "./cli.js", "y": "bin/y.js"}, "browser": "browser.js"}`,
+		".npmignore":          "bin",
+		"npm-shrinkwrap.json": "{}",
+		"cli.js":              "cli",
+		"bin/y.js":            "y",
+		"bin/z.js":            "z",
+		"browser.js":          "browser",
+		"lib/a.js":            "a",
+		"other.js":            "other",
+	})
+
+	tarball, err := Pack(dir)
+	if assert.Nil(t, err) {
+		expected := []string{
+			"bin/y.js",
+			"browser.js",
+			"cli.js",
+			"lib/a.js",
+			"npm-shrinkwrap.json",
+			"package.json",
+		}
+		assert.Equal(t, expected, paths(tarball.Files))
+	}
+
+	// a single bin and a browser map of replacements
+	dir = writeFiles(t, map[string]string{
+		"package.json": `{"name": "my-package", "version": "1.0.0", "files": ["lib"], "bin": "cli.js", "browser": {"./a.js": "./b.js"}}`,
+		"cli.js":       "cli",
+		"b.js":         "b",
+		"lib/a.js":     "a",
+	})
+
+	tarball, err = Pack(dir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"cli.js", "lib/a.js", "package.json"}, paths(tarball.Files))
+	}
+}
+
+func TestPackIsReproducible(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json": `{"name": "my-package", "version": "1.0.0"}`,
+		"index.js":     "index",
+	})
+
+	first, err := Pack(dir)
+	assert.Nil(t, err)
+	second, err := Pack(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, first.Integrity, second.Integrity)
+}
+
+func TestPackMissingPackageFile(t *testing.T) {
+	_, err := Pack(t.TempDir())
+	assert.NotNil(t, err)
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		".":             "",
+		"/":             "",
+		"./lib/../a.js": "a.js",
+		"/bin/cli.js":   "bin/cli.js",
+		"lib/":          "lib",
+	}
+	for entry, expected := range tests {
+		assert.Equal(t, expected, CleanPath(entry), entry)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// packagePrefix is the directory npm places the files of a package in within
+// its tarball.
+const packagePrefix = "package/"
+
+// Read loads a tarball created beforehand, such as by npm pack, reading the
+// name and version from the package/package.json within it. The data of the
+// tarball is kept as is so exactly those bytes are published.
+func Read(file string) (*Tarball, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read tarball: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a gzip tarball: %w", file, err)
+	}
+	tr := tar.NewReader(gz)
+
+	tarball := &Tarball{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", file, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := strings.TrimPrefix(header.Name, packagePrefix)
+		tarball.Files = append(tarball.Files, File{Path: name, Size: header.Size})
+		tarball.UnpackedSize += header.Size
+
+		if header.Name != packagePrefix+"package.json" {
+			continue
+		}
+		if tarball.Manifest, err = io.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("could not read package.json from %s: %w", file, err)
+		}
+	}
+
+	if tarball.Manifest == nil {
+		return nil, fmt.Errorf("no %spackage.json in %s", packagePrefix, file)
+	}
+
+	m := manifest{}
+	if err := json.Unmarshal(tarball.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("could not parse package.json from %s: %w", file, err)
+	}
+	tarball.Name = m.Name
+	tarball.Version = m.Version
+
+	tarball.setData(data)
+
+	return tarball, nil
+}
+
+// SetManifest replaces the package/package.json within the tarball, such as
+// to publish the package with another version without changing the files it
+// was packed from. The data and checksums are updated to the new contents.
+func (t *Tarball) SetManifest(contents []byte) error {
+	m := manifest{}
+	if err := json.Unmarshal(contents, &m); err != nil {
+		return fmt.Errorf("could not parse package.json: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(t.Data))
+	if err != nil {
+		return fmt.Errorf("tarball is not gzipped: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	replaced := false
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tarball: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", header.Name, err)
+		}
+		if header.Typeflag == tar.TypeReg && header.Name == packagePrefix+"package.json" {
+			data = contents
+			header.Size = int64(len(data))
+			replaced = true
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if !replaced {
+		return fmt.Errorf("no %spackage.json in tarball", packagePrefix)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+
+	for i, file := range t.Files {
+		if file.Path == "package.json" {
+			t.Files[i].Size = int64(len(contents))
+		}
+	}
+	t.UnpackedSize += int64(len(contents) - len(t.Manifest))
+	t.Name = m.Name
+	t.Version = m.Version
+	t.Manifest = contents
+	t.setData(buf.Bytes())
+
+	return nil
+}
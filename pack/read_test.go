@@ -0,0 +1,112 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json": `{"name": "my-package", "version": "1.0.0"}`,
+		"index.js":     "index",
+	})
+
+	packed, err := Pack(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "my-package-1.0.0.tgz")
+	if err := os.WriteFile(file, packed.Data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball, err := Read(file)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "my-package", tarball.Name)
+		assert.Equal(t, "1.0.0", tarball.Version)
+		assert.Equal(t, packed.Manifest, tarball.Manifest)
+		assert.Equal(t, packed.Data, tarball.Data)
+		assert.Equal(t, packed.Integrity, tarball.Integrity)
+		assert.Equal(t, packed.UnpackedSize, tarball.UnpackedSize)
+		assert.ElementsMatch(t, []string{"index.js", "package.json"}, paths(tarball.Files))
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Read(filepath.Join(dir, "missing.tgz"))
+	assert.NotNil(t, err)
+
+	file := filepath.Join(dir, "plain.tgz")
+	if err := os.WriteFile(file, []byte("not a tarball"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Read(file)
+	assert.NotNil(t, err)
+
+	// the package.json must be within the package directory
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	contents := []byte(`{"name": "my-package", "version": "1.0.0"}`)
+	tw.WriteHeader(&tar.Header{Name: "package.json", Mode: 0o644, Size: int64(len(contents))}) //nolint:errcheck
+	tw.Write(contents)                                                                         //nolint:errcheck
+	tw.Close()                                                                                 //nolint:errcheck
+	gz.Close()                                                                                 //nolint:errcheck
+
+	file = filepath.Join(dir, "unprefixed.tgz")
+	if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Read(file)
+	assert.NotNil(t, err)
+}
+
+func TestSetManifest(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"package.json": `{"name": "my-package", "version": "1.0.0"}`,
+		"index.js":     "index",
+	})
+
+	tarball, err := Pack(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := tarball.Integrity
+
+	contents := []byte(`{"name": "my-package", "version": "1.0.0-canary.1"}`)
+	if assert.Nil(t, tarball.SetManifest(contents)) {
+		assert.Equal(t, "1.0.0-canary.1", tarball.Version)
+		assert.NotEqual(t, original, tarball.Integrity)
+		assert.Equal(t, []string{"package/index.js", "package/package.json"}, tarEntries(t, tarball.Data))
+
+		// the tarball reads back with the replaced contents
+		file := filepath.Join(t.TempDir(), "my-package.tgz")
+		if err := os.WriteFile(file, tarball.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		read, err := Read(file)
+		if assert.Nil(t, err) {
+			assert.Equal(t, contents, read.Manifest)
+			assert.Equal(t, tarball.UnpackedSize, read.UnpackedSize)
+			assert.Equal(t, tarball.Integrity, read.Integrity)
+		}
+	}
+
+	// the files on disk are left alone
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	assert.Equal(t, `{"name": "my-package", "version": "1.0.0"}`, string(data))
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// packageManager generates the commands and credential file of the
+	// client publishing the package.
+	packageManager interface {
+		// versionCommand prints the version of the client.
+		versionCommand() *exec.Cmd
+
+		// whoamiCommand verifies the credentials.
+		whoamiCommand() *exec.Cmd
+
+		// publishCommand publishes the package with the dist-tag.
+		publishCommand(settings *Settings, tag string) *exec.Cmd
+
+		// packCommand packs the package into a tarball in the directory.
+		packCommand(destination string) *exec.Cmd
+
+		// distTagAddCommand points the dist-tag at the version.
+		distTagAddCommand(name, version, tag string) *exec.Cmd
+
+		// distTagRemoveCommand removes the dist-tag.
+		distTagRemoveCommand(name, tag string) *exec.Cmd
+
+		// deprecateCommand sets the deprecation message of the version, an
+		// empty message undeprecates it.
+		deprecateCommand(name, version, message string) *exec.Cmd
+
+		// unpublishCommand removes the version, forcing it when it is the
+		// only version and the whole package is removed.
+		unpublishCommand(name, version string, force bool) *exec.Cmd
+
+		// configFile is the name of the credential file.
+		configFile() string
+
+		// configContents creates the contents of the credential file.
+		configContents(settings *Settings, skipVerify bool) (string, error)
+
+		// configEnv points the client to the written credential file.
+		configEnv(settings *Settings, path string, skipVerify bool) []string
+	}
+
+	// npmManager publishes with the npm CLI.
+	npmManager struct{}
+
+	// pnpmManager publishes with pnpm which rewrites workspace: protocol
+	// dependencies while publishing.
+	pnpmManager struct{}
+
+	// yarnManager publishes with Yarn Berry which is configured through YARN_
+	// environment variables and a .yarnrc.yml instead of an npmrc.
+	yarnManager struct{}
+)
+
+const (
+	npmClient  = "npm"
+	pnpmClient = "pnpm"
+	yarnClient = "yarn"
+)
+
+// newPackageManager returns the packageManager for the named client.
+func newPackageManager(name string) (packageManager, error) {
+	switch name {
+	case "", npmClient:
+		return npmManager{}, nil
+	case pnpmClient:
+		return pnpmManager{}, nil
+	case yarnClient:
+		return yarnManager{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported client %s, expected npm, pnpm or yarn", name)
+}
+
+// manager returns the packageManager for the configured client.
+func (p *Plugin) manager() packageManager {
+	if p.settings.manager == nil {
+		return npmManager{}
+	}
+
+	return p.settings.manager
+}
+
+// versionCommand gets the npm version
+func (npmManager) versionCommand() *exec.Cmd {
+	return exec.Command("npm", "--version")
+}
+
+// whoamiCommand creates a command that gets the currently logged in user.
+func (npmManager) whoamiCommand() *exec.Cmd {
+	return exec.Command("npm", "whoami")
+}
+
+// publishCommand runs the publish command, reporting the published tarball
+// as json when it is verified so its integrity can be compared.
+func (npmManager) publishCommand(settings *Settings, tag string) *exec.Cmd {
+	commandArgs := publishArgs(settings, tag)
+	if settings.VerifyPublish {
+		commandArgs = append(commandArgs, "--json")
+	}
+
+	return exec.Command("npm", commandArgs...)
+}
+
+func (npmManager) packCommand(destination string) *exec.Cmd {
+	return exec.Command("npm", "pack", "--pack-destination", destination)
+}
+
+func (npmManager) distTagAddCommand(name, version, tag string) *exec.Cmd {
+	return exec.Command("npm", "dist-tag", "add", name+"@"+version, tag)
+}
+
+func (npmManager) distTagRemoveCommand(name, tag string) *exec.Cmd {
+	return exec.Command("npm", "dist-tag", "rm", name, tag)
+}
+
+func (npmManager) deprecateCommand(name, version, message string) *exec.Cmd {
+	return exec.Command("npm", "deprecate", name+"@"+version, message)
+}
+
+func (npmManager) unpublishCommand(name, version string, force bool) *exec.Cmd {
+	return exec.Command("npm", unpublishArgs(name, version, force)...)
+}
+
+func (npmManager) configFile() string {
+	return ".npmrc"
+}
+
+// configContents creates an npmrc which also holds the registry settings, so
+// rewriting it keeps them.
+func (npmManager) configContents(settings *Settings, skipVerify bool) (string, error) {
+	contents, err := npmrcContents(settings)
+	if err != nil {
+		return "", err
+	}
+
+	network, err := npmrcContentsNetwork(settings)
+	if err != nil {
+		return "", err
+	}
+
+	contents += network + "\nregistry=" + settings.Registry
+	if skipVerify {
+		contents += "\nstrict-ssl=false"
+	}
+
+	return contents, nil
+}
+
+func (npmManager) configEnv(settings *Settings, path string, skipVerify bool) []string {
+	return append([]string{"NPM_CONFIG_USERCONFIG=" + path}, caEnv(settings)...)
+}
+
+func (pnpmManager) versionCommand() *exec.Cmd {
+	return exec.Command("pnpm", "--version")
+}
+
+func (pnpmManager) whoamiCommand() *exec.Cmd {
+	return exec.Command("pnpm", "whoami")
+}
+
+// publishCommand runs the publish command without the git checks, as builds
+// commonly check out a detached commit.
+func (pnpmManager) publishCommand(settings *Settings, tag string) *exec.Cmd {
+	return exec.Command("pnpm", append(publishArgs(settings, tag), "--no-git-checks")...)
+}
+
+// packCommand runs the pack command which rewrites workspace: protocol
+// dependencies like publishing does.
+func (pnpmManager) packCommand(destination string) *exec.Cmd {
+	return exec.Command("pnpm", "pack", "--pack-destination", destination)
+}
+
+// distTagAddCommand runs the dist-tag command pnpm passes through to npm.
+func (pnpmManager) distTagAddCommand(name, version, tag string) *exec.Cmd {
+	return exec.Command("pnpm", "dist-tag", "add", name+"@"+version, tag)
+}
+
+func (pnpmManager) distTagRemoveCommand(name, tag string) *exec.Cmd {
+	return exec.Command("pnpm", "dist-tag", "rm", name, tag)
+}
+
+// deprecateCommand runs the deprecate command pnpm passes through to npm.
+func (pnpmManager) deprecateCommand(name, version, message string) *exec.Cmd {
+	return exec.Command("pnpm", "deprecate", name+"@"+version, message)
+}
+
+// unpublishCommand runs the unpublish command pnpm passes through to npm.
+func (pnpmManager) unpublishCommand(name, version string, force bool) *exec.Cmd {
+	return exec.Command("pnpm", unpublishArgs(name, version, force)...)
+}
+
+func (pnpmManager) configFile() string {
+	return ".npmrc"
+}
+
+// configContents creates the same npmrc as npm.
+func (pnpmManager) configContents(settings *Settings, skipVerify bool) (string, error) {
+	return npmManager{}.configContents(settings, skipVerify)
+}
+
+func (pnpmManager) configEnv(settings *Settings, path string, skipVerify bool) []string {
+	return npmManager{}.configEnv(settings, path, skipVerify)
+}
+
+func (yarnManager) versionCommand() *exec.Cmd {
+	return exec.Command("yarn", "--version")
+}
+
+func (yarnManager) whoamiCommand() *exec.Cmd {
+	return exec.Command("yarn", "npm", "whoami")
+}
+
+// publishCommand runs the publish command. Yarn can't publish as a dry run so
+// the package is packed as a dry run instead to list its contents.
+func (yarnManager) publishCommand(settings *Settings, tag string) *exec.Cmd {
+	if settings.DryRun {
+		return exec.Command("yarn", "pack", "--dry-run")
+	}
+
+	commandArgs := []string{"npm", "publish"}
+	if tag != "" {
+		commandArgs = append(commandArgs, "--tag", tag)
+	}
+	if settings.Access != "" {
+		commandArgs = append(commandArgs, "--access", settings.Access)
+	}
+
+	return exec.Command("yarn", commandArgs...)
+}
+
+func (yarnManager) packCommand(destination string) *exec.Cmd {
+	return exec.Command("yarn", "pack", "--out", filepath.Join(destination, "package.tgz"))
+}
+
+func (yarnManager) distTagAddCommand(name, version, tag string) *exec.Cmd {
+	return exec.Command("yarn", "npm", "tag", "add", name+"@"+version, tag)
+}
+
+func (yarnManager) distTagRemoveCommand(name, tag string) *exec.Cmd {
+	return exec.Command("yarn", "npm", "tag", "remove", name, tag)
+}
+
+// deprecateCommand falls back to npm as yarn can't deprecate versions,
+// validation rejects deprecating with yarn.
+func (yarnManager) deprecateCommand(name, version, message string) *exec.Cmd {
+	return npmManager{}.deprecateCommand(name, version, message)
+}
+
+// unpublishCommand falls back to npm as yarn can't unpublish versions,
+// validation rejects unpublishing with yarn.
+func (yarnManager) unpublishCommand(name, version string, force bool) *exec.Cmd {
+	return npmManager{}.unpublishCommand(name, version, force)
+}
+
+func (yarnManager) configFile() string {
+	return ".yarnrc.yml"
+}
+
+// configContents creates a .yarnrc.yml with the registry and credentials of
+// each scope. Yarn can't configure scopes through the environment, everything
+// else is passed by configEnv.
+func (yarnManager) configContents(settings *Settings, skipVerify bool) (string, error) {
+	var b strings.Builder
+
+	names := sortedScopes(settings.scopes)
+
+	if len(names) > 0 {
+		b.WriteString("npmScopes:\n")
+	}
+	for _, scope := range names {
+		config := settings.scopes[scope]
+
+		fmt.Fprintf(&b, "  %s:\n", yamlString(strings.TrimPrefix(scope, "@")))
+		fmt.Fprintf(&b, "    npmRegistryServer: %s\n", yamlString(config.Registry))
+		writeYarnAuth(&b, "    ", config.Token, config.Username, config.Password)
+	}
+
+	return b.String(), nil
+}
+
+// configEnv passes the registry, credentials and network settings as YARN_
+// variables, which take precedence over every .yarnrc.yml including the one
+// of the project. Yarn only reads the scopes from the project and home
+// directories, so the home directory points to the folder of the .yarnrc.yml
+// when there are scopes, keeping the caches of the real home directory.
+func (yarnManager) configEnv(settings *Settings, path string, skipVerify bool) []string {
+	env := []string{
+		"YARN_NPM_REGISTRY_SERVER=" + settings.Registry,
+		"YARN_NPM_ALWAYS_AUTH=true",
+	}
+
+	switch {
+	case settings.Token != "":
+		env = append(env, "YARN_NPM_AUTH_TOKEN="+settings.Token)
+	case settings.Username != "":
+		env = append(env, "YARN_NPM_AUTH_IDENT="+settings.Username+":"+settings.Password)
+	}
+	if skipVerify {
+		env = append(env, "YARN_ENABLE_STRICT_SSL=false")
+	}
+	env = append(env, caEnv(settings)...)
+	if settings.certFile != "" {
+		env = append(env,
+			"YARN_HTTPS_CERT_FILE_PATH="+settings.certFile,
+			"YARN_HTTPS_KEY_FILE_PATH="+settings.keyFile,
+		)
+	}
+	if settings.Proxy != "" {
+		env = append(env, "YARN_HTTP_PROXY="+settings.Proxy)
+	}
+	if httpsProxy := settings.HTTPSProxy; httpsProxy != "" || settings.Proxy != "" {
+		// npm falls back to the http proxy while yarn doesn't
+		if httpsProxy == "" {
+			httpsProxy = settings.Proxy
+		}
+		env = append(env, "YARN_HTTPS_PROXY="+httpsProxy)
+	}
+
+	if len(settings.scopes) > 0 {
+		env = append(env, yarnHomeEnv(filepath.Dir(path))...)
+	}
+
+	return env
+}
+
+// yarnHomeEnv points the home directory to home while keeping the corepack
+// and yarn caches in the real home directory, so yarn isn't downloaded again.
+func yarnHomeEnv(home string) []string {
+	env := []string{"HOME=" + home}
+
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return env
+	}
+	if os.Getenv("COREPACK_HOME") == "" {
+		cache := os.Getenv("XDG_CACHE_HOME")
+		if cache == "" {
+			cache = filepath.Join(userHome, ".cache")
+		}
+		env = append(env, "COREPACK_HOME="+filepath.Join(cache, "node", "corepack"))
+	}
+	if os.Getenv("YARN_GLOBAL_FOLDER") == "" {
+		env = append(env, "YARN_GLOBAL_FOLDER="+filepath.Join(userHome, ".yarn", "berry"))
+	}
+
+	return env
+}
+
+// publishArgs creates the arguments of the publish command shared by npm and
+// pnpm.
+func publishArgs(settings *Settings, tag string) []string {
+	commandArgs := []string{"publish"}
+
+	if settings.tarball != "" {
+		commandArgs = append(commandArgs, settings.tarball)
+	}
+
+	if tag != "" {
+		commandArgs = append(commandArgs, "--tag", tag)
+	}
+
+	if settings.Access != "" {
+		commandArgs = append(commandArgs, "--access", settings.Access)
+	}
+
+	if settings.DryRun {
+		commandArgs = append(commandArgs, "--dry-run")
+	}
+
+	return commandArgs
+}
+
+// unpublishArgs creates the arguments of the unpublish command shared by npm
+// and pnpm.
+func unpublishArgs(name, version string, force bool) []string {
+	commandArgs := []string{"unpublish", name + "@" + version}
+
+	if force {
+		commandArgs = append(commandArgs, "--force")
+	}
+
+	return commandArgs
+}
+
+// npmrcContents creates the npmrc credentials for the registry and scopes.
+// The registry has no credentials until an oidc token is exchanged.
+func npmrcContents(settings *Settings) (string, error) {
+	scopes, err := npmrcContentsScopes(settings.scopes)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case settings.Token != "":
+		token, err := npmrcContentsToken(settings)
+		if err != nil {
+			return "", err
+		}
+		return token + scopes, nil
+	case settings.OIDCToken != "":
+		return strings.TrimPrefix(scopes, "\n"), nil
+	}
+
+	return npmrcContentsUsernamePassword(settings) + scopes, nil
+}
+
+// writeYarnAuth writes the yarn credentials for a registry.
+func writeYarnAuth(b *strings.Builder, indent, token, username, password string) {
+	switch {
+	case token != "":
+		fmt.Fprintf(b, "%snpmAuthToken: %s\n", indent, yamlString(token))
+	case username != "":
+		fmt.Fprintf(b, "%snpmAuthIdent: %s\n", indent, yamlString(username+":"+password))
+	}
+}
+
+// yamlString quotes the value as a YAML string, which JSON strings are.
+func yamlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPackageManager(t *testing.T) {
+	for name, expected := range map[string]packageManager{
+		"":     npmManager{},
+		"npm":  npmManager{},
+		"pnpm": pnpmManager{},
+		"yarn": yarnManager{},
+	} {
+		manager, err := newPackageManager(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, expected, manager, name)
+	}
+
+	_, err := newPackageManager("bun")
+	assert.NotNil(t, err)
+}
+
+func TestClientPublishCommands(t *testing.T) {
+	settings := Settings{Access: "public"}
+
+	for _, test := range []struct {
+		manager  packageManager
+		dryRun   bool
+		expected string
+	}{
+		{manager: npmManager{}, expected: "npm publish --tag next --access public"},
+		{manager: pnpmManager{}, expected: "pnpm publish --tag next --access public --no-git-checks"},
+		{manager: pnpmManager{}, dryRun: true, expected: "pnpm publish --tag next --access public --dry-run --no-git-checks"},
+		{manager: yarnManager{}, expected: "yarn npm publish --tag next --access public"},
+		{manager: yarnManager{}, dryRun: true, expected: "yarn pack --dry-run"},
+	} {
+		settings.DryRun = test.dryRun
+		actual := strings.Join(test.manager.publishCommand(&settings, "next").Args, " ")
+		assert.Equal(t, test.expected, actual)
+	}
+}
+
+func TestClientConfigContents(t *testing.T) {
+	settings := Settings{
+		Registry: "https://npm.acme.com/",
+		Token:    "token",
+		scopes: map[string]scopeConfig{
+			"@basic": {Registry: "https://npm.basic.com/", Username: "user", Password: "pa\"ss"},
+			"@acme":  {Registry: "https://npm.acme.com/", Token: "scoped"},
+		},
+	}
+
+	contents, err := npmManager{}.configContents(&settings, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "//npm.acme.com/:_authToken=token"+
+		"\n@acme:registry=https://npm.acme.com/"+
+		"\n//npm.acme.com/:_authToken=scoped"+
+		"\n@basic:registry=https://npm.basic.com/"+
+		"\n//npm.basic.com/:_auth=dXNlcjpwYSJzcw=="+
+		"\nregistry=https://npm.acme.com/"+
+		"\nstrict-ssl=false",
+		contents)
+
+	contents, _ = pnpmManager{}.configContents(&settings, true)
+	assert.True(t, strings.HasSuffix(contents, "\nregistry=https://npm.acme.com/\nstrict-ssl=false"))
+
+	contents, _ = yarnManager{}.configContents(&settings, true)
+	assert.Equal(t, `npmScopes:
+  "acme":
+    npmRegistryServer: "https://npm.acme.com/"
+    npmAuthToken: "scoped"
+  "basic":
+    npmRegistryServer: "https://npm.basic.com/"
+    npmAuthIdent: "user:pa\"ss"
+`, contents)
+
+	settings.Token = ""
+	settings.Username = "user"
+	settings.Password = "pass"
+	settings.scopes = nil
+	contents, _ = yarnManager{}.configContents(&settings, false)
+	assert.Empty(t, contents)
+
+	// an unparsable registry is an error rather than a panic
+	settings.Registry = "https://bad host/"
+	settings.Token = "token"
+	_, err = npmManager{}.configContents(&settings, false)
+	assert.NotNil(t, err)
+}
+
+func TestExecuteWithYarn(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusNotFound, "")
+	p.settings.Client = "yarn"
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var config string
+	var contents []byte
+	runner.inspect = func(cmd *exec.Cmd) {
+		config = p.npmrc
+		contents, _ = os.ReadFile(config)
+	}
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"yarn --version",
+			"yarn npm whoami",
+			"yarn npm publish",
+		}, runner.commands)
+		assert.Equal(t, ".yarnrc.yml", filepath.Base(config))
+		assert.Empty(t, contents)
+
+		// the environment takes precedence over the .yarnrc.yml of the project
+		for _, env := range runner.envs {
+			assert.Contains(t, env, "YARN_NPM_REGISTRY_SERVER="+p.settings.Registry)
+			assert.Contains(t, env, "YARN_NPM_AUTH_TOKEN=token")
+			assert.Contains(t, env, "YARN_ENABLE_STRICT_SSL=false")
+			assert.NotContains(t, env, "HOME="+filepath.Dir(config))
+		}
+	}
+}
+
+func TestYarnConfigEnv(t *testing.T) {
+	settings := Settings{
+		Registry: "https://npm.acme.com/",
+		Username: "user",
+		Password: "pass",
+		Proxy:    "http://proxy.acme.com:8080",
+		caFile:   "/etc/ssl/acme.pem",
+		certFile: "/tmp/cert.pem",
+		keyFile:  "/tmp/key.pem",
+	}
+
+	assert.Equal(t, []string{
+		"YARN_NPM_REGISTRY_SERVER=https://npm.acme.com/",
+		"YARN_NPM_ALWAYS_AUTH=true",
+		"YARN_NPM_AUTH_IDENT=user:pass",
+		"NODE_EXTRA_CA_CERTS=/etc/ssl/acme.pem",
+		"YARN_HTTPS_CERT_FILE_PATH=/tmp/cert.pem",
+		"YARN_HTTPS_KEY_FILE_PATH=/tmp/key.pem",
+		"YARN_HTTP_PROXY=http://proxy.acme.com:8080",
+		"YARN_HTTPS_PROXY=http://proxy.acme.com:8080",
+	}, yarnManager{}.configEnv(&settings, "/tmp/drone-npm/.yarnrc.yml", false))
+
+	// scopes are only read from the .yarnrc.yml in the home directory, the
+	// caches stay in the real one
+	t.Setenv("HOME", "/home/drone")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("COREPACK_HOME", "")
+	t.Setenv("YARN_GLOBAL_FOLDER", "")
+	settings.scopes = map[string]scopeConfig{"@acme": {Registry: "https://npm.acme.com/"}}
+	env := yarnManager{}.configEnv(&settings, "/tmp/drone-npm/.yarnrc.yml", false)
+	assert.Equal(t, []string{
+		"HOME=/tmp/drone-npm",
+		"COREPACK_HOME=/home/drone/.cache/node/corepack",
+		"YARN_GLOBAL_FOLDER=/home/drone/.yarn/berry",
+	}, env[len(env)-3:])
+}
+
+func TestValidateClient(t *testing.T) {
+	p := initPlugin()
+	p.settings.SkipRegistryValidation = true
+
+	p.settings.Client = "bun"
+	assert.NotNil(t, p.Validate())
+
+	p.settings.Client = "pnpm"
+	p.settings.HTTPPublish = true
+	assert.NotNil(t, p.Validate())
+
+	p.settings.Client = "yarn"
+	p.settings.HTTPPublish = false
+	p.settings.MergeNpmrc = true
+	assert.NotNil(t, p.Validate())
+
+	p.settings.MergeNpmrc = false
+	if assert.Nil(t, p.Validate()) {
+		assert.Equal(t, yarnManager{}, p.manager())
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/semver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// deprecateAction deprecates the published versions in a range.
+	deprecateAction = "deprecate"
+
+	// undeprecateAction removes the deprecation of the published versions in
+	// a range.
+	undeprecateAction = "undeprecate"
+)
+
+// parseDeprecation verifies the range and message of the deprecate and
+// undeprecate actions.
+func (p *Plugin) parseDeprecation() error {
+	if err := p.validateManagement(false); err != nil {
+		return err
+	}
+
+	if p.settings.DeprecateRange == "" {
+		return fmt.Errorf("no range of versions to %s", p.settings.Action)
+	}
+	// npm deprecates the prereleases in the range like other versions
+	versions, err := semver.ParseRangeIncludePrerelease(p.settings.DeprecateRange)
+	if err != nil {
+		return err
+	}
+
+	switch p.settings.Action {
+	case deprecateAction:
+		if p.settings.DeprecateMessage == "" {
+			return fmt.Errorf("no deprecation message provided")
+		}
+		p.settings.deprecation = p.settings.DeprecateMessage
+	case undeprecateAction:
+		p.settings.deprecation = ""
+	}
+
+	p.settings.deprecateRange = versions
+	return nil
+}
+
+// manageDeprecations sets the deprecation of the published versions in the
+// range, leaving out those which already have it. The affected versions are
+// listed before anything is changed, which is all a dry run does.
+func (p *Plugin) manageDeprecations(npm *npmPackage) error {
+	client, err := p.registryClient()
+	if err != nil {
+		return err
+	}
+
+	packument, err := p.lookupPackument(client, npm)
+	if err != nil {
+		return err
+	}
+
+	versions := p.deprecationVersions(packument)
+
+	logrus.WithFields(logrus.Fields{
+		"name":     npm.Name,
+		"range":    p.settings.DeprecateRange,
+		"versions": len(versions),
+	}).Info("Resolved versions in range")
+
+	if len(versions) == 0 {
+		logrus.Info("No versions to update")
+		return nil
+	}
+	printDeprecation(p.settings.Action, p.settings.deprecation, versions)
+
+	if p.settings.DryRun {
+		logrus.Info("Dry run, not updating versions")
+		return nil
+	}
+
+	if p.settings.HTTPPublish {
+		messages := map[string]string{}
+		for _, version := range versions {
+			messages[version] = p.settings.deprecation
+		}
+
+		return p.retry(p.settings.Action, func() error {
+			return client.Deprecate(p.context(), npm.Name, messages)
+		}, nil)
+	}
+
+	for _, version := range versions {
+		err := p.retry(p.settings.Action, func() error {
+			return p.runCommand(p.manager().deprecateCommand(npm.Name, version, p.settings.deprecation), npm.folder)
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("could not %s %s: %w", p.settings.Action, version, err)
+		}
+	}
+
+	return nil
+}
+
+// deprecationVersions returns the published versions in the range whose
+// deprecation differs, ordered by precedence.
+func (p *Plugin) deprecationVersions(packument *registry.Packument) []string {
+	type match struct {
+		raw     string
+		version *semver.Version
+	}
+
+	var matches []match
+	for raw, manifest := range packument.Versions {
+		version, err := semver.Parse(raw)
+		if err != nil {
+			logrus.WithField("version", raw).Debug("Skipping invalid version")
+			continue
+		}
+		if !p.settings.deprecateRange.Contains(version) || manifest.Deprecated == p.settings.deprecation {
+			continue
+		}
+
+		matches = append(matches, match{raw: raw, version: version})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return semver.Compare(matches[i].version, matches[j].version) < 0
+	})
+
+	versions := make([]string, len(matches))
+	for i, m := range matches {
+		versions[i] = m.raw
+	}
+
+	return versions
+}
+
+// printDeprecation writes the versions the action affects to standard out.
+func printDeprecation(action, message string, versions []string) {
+	fmt.Fprintf(os.Stdout, "Versions to %s\n", action)
+	for _, version := range versions {
+		fmt.Fprintf(os.Stdout, "  %s\n", version)
+	}
+	if message != "" {
+		fmt.Fprintf(os.Stdout, "message: %s\n", message)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeprecation(t *testing.T) {
+	p := initPlugin()
+	p.settings.Action = "deprecate"
+	assert.NotNil(t, p.parseDeprecation())
+
+	p.settings.DeprecateRange = "latest"
+	p.settings.DeprecateMessage = "vulnerable"
+	assert.NotNil(t, p.parseDeprecation())
+
+	p.settings.DeprecateRange = "<1.2.3"
+	p.settings.DeprecateMessage = ""
+	assert.NotNil(t, p.parseDeprecation())
+
+	p.settings.DeprecateMessage = "vulnerable"
+	if assert.Nil(t, p.parseDeprecation()) {
+		assert.Equal(t, "vulnerable", p.settings.deprecation)
+	}
+
+	p.settings.Action = "undeprecate"
+	if assert.Nil(t, p.parseDeprecation()) {
+		assert.Equal(t, "", p.settings.deprecation)
+	}
+
+	p.settings.Client = "yarn"
+	assert.NotNil(t, p.parseDeprecation())
+}
+
+func TestExecuteDeprecate(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusOK,
+		`"1.0.0": {}, "1.1.0": {"deprecated": "vulnerable"}, "1.2.0": {}, "1.3.0-beta.1": {}, "1.10.0": {}, "2.0.0-rc.1": {}, "2.0.0": {}, "latest": {}`)
+	p.settings.SkipWhoami = true
+	p.settings.Action = "deprecate"
+	p.settings.DeprecateRange = "^1.0.0"
+	p.settings.DeprecateMessage = "vulnerable"
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// versions already deprecated with the message are left alone while
+	// prereleases in the range are deprecated too
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"npm deprecate my-awesome-package@1.0.0 vulnerable",
+			"npm deprecate my-awesome-package@1.2.0 vulnerable",
+			"npm deprecate my-awesome-package@1.3.0-beta.1 vulnerable",
+			"npm deprecate my-awesome-package@1.10.0 vulnerable",
+		}, runner.commands[len(runner.commands)-4:])
+	}
+
+	// a dry run only lists the versions
+	runner.commands = nil
+	p.settings.DryRun = true
+	if assert.Nil(t, p.Execute()) {
+		for _, command := range runner.commands {
+			assert.NotContains(t, command, "deprecate")
+		}
+	}
+}
+
+func TestExecuteDeprecateAgainstRegistry(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	for _, version := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+		manifest := []byte(`{"name": "my-awesome-package", "version": "` + version + `"}`)
+		if err := client.Publish(context.TODO(), manifest, []byte(version), "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.Action = "deprecate"
+	p.settings.DeprecateRange = "<2"
+	p.settings.DeprecateMessage = "vulnerable"
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.Contains(t, string(pkg.Versions["1.0.0"]), `"deprecated":"vulnerable"`)
+		assert.Contains(t, string(pkg.Versions["1.1.0"]), `"deprecated":"vulnerable"`)
+		assert.NotContains(t, string(pkg.Versions["2.0.0"]), "deprecated")
+	}
+
+	p.settings.Action = "undeprecate"
+	p.settings.DeprecateRange = "1.1.0"
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.Contains(t, string(pkg.Versions["1.0.0"]), `"deprecated":"vulnerable"`)
+		assert.NotContains(t, string(pkg.Versions["1.1.0"]), "deprecated")
+	}
+}
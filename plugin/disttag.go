@@ -0,0 +1,207 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/semver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// publishAction publishes the package.
+	publishAction = "publish"
+
+	// distTagAction adds, moves or removes dist-tags of a published version.
+	distTagAction = "dist-tag"
+)
+
+// validateAction verifies the action of the plugin and the settings it
+// requires.
+func (p *Plugin) validateAction() error {
+	switch p.settings.Action {
+	case "", publishAction:
+		return nil
+	case distTagAction:
+		return p.parseDistTags()
+	case deprecateAction, undeprecateAction:
+		return p.parseDeprecation()
+	case unpublishAction:
+		return p.parseUnpublish()
+	}
+
+	return fmt.Errorf("unsupported action %s, expected %s, %s, %s, %s or %s",
+		p.settings.Action, publishAction, distTagAction, deprecateAction, undeprecateAction, unpublishAction)
+}
+
+// publishing determines whether the action publishes the package.
+func (p *Plugin) publishing() bool {
+	return p.settings.Action == "" || p.settings.Action == publishAction
+}
+
+// validateManagement verifies the settings shared by the actions managing
+// published versions, which work on a single package and need credentials
+// beyond an oidc token. Not every action is supported by yarn.
+func (p *Plugin) validateManagement(yarnSupported bool) error {
+	if p.settings.Workspaces {
+		return fmt.Errorf("the %s action does not support workspaces", p.settings.Action)
+	}
+	if p.settings.OIDCToken != "" {
+		return fmt.Errorf("the %s action requires credentials, an oidc token only allows publishing", p.settings.Action)
+	}
+	if !yarnSupported && p.settings.Client == yarnClient {
+		return fmt.Errorf("the %s action is not supported by yarn", p.settings.Action)
+	}
+
+	return nil
+}
+
+// lookupPackument retrieves the packument of the package managed by an
+// action, which must be published.
+func (p *Plugin) lookupPackument(client *registry.Client, npm *npmPackage) (*registry.Packument, error) {
+	var packument *registry.Packument
+	err := p.retry("lookup", func() (err error) {
+		packument, err = client.Packument(p.context(), npm.Name)
+		return err
+	}, nil)
+	if errors.Is(err, registry.ErrNotFound) {
+		return nil, fmt.Errorf("package %s is not published", npm.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve package versions: %w", err)
+	}
+
+	return packument, nil
+}
+
+// parseDistTags reads the comma separated dist-tags to add and remove from
+// the settings.
+func (p *Plugin) parseDistTags() error {
+	if err := p.validateManagement(true); err != nil {
+		return err
+	}
+
+	p.settings.addTags = splitList(p.settings.AddDistTags)
+	p.settings.removeTags = splitList(p.settings.RemoveDistTags)
+	if len(p.settings.addTags) == 0 && len(p.settings.removeTags) == 0 {
+		return fmt.Errorf("no dist-tags to add or remove")
+	}
+
+	added := map[string]bool{}
+	for _, tag := range p.settings.addTags {
+		if _, err := semver.Parse(tag); err == nil {
+			return fmt.Errorf("dist-tag %s cannot be a version", tag)
+		}
+		added[tag] = true
+	}
+	for _, tag := range p.settings.removeTags {
+		if tag == latestTag {
+			return fmt.Errorf("the %s dist-tag cannot be removed", latestTag)
+		}
+		if added[tag] {
+			return fmt.Errorf("dist-tag %s cannot be both added and removed", tag)
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// manageDistTags points the dist-tags to add at the version of the package
+// and removes the dist-tags to remove. The version must be published and
+// dist-tags which are already as requested are left alone.
+func (p *Plugin) manageDistTags(npm *npmPackage) error {
+	version := p.settings.DistTagVersion
+	if version == "" {
+		version = npm.Version
+	}
+
+	client, err := p.registryClient()
+	if err != nil {
+		return err
+	}
+
+	packument, err := p.lookupPackument(client, npm)
+	if err != nil {
+		return err
+	}
+
+	if _, found := packument.Versions[version]; !found {
+		return fmt.Errorf("version %s of %s is not published", version, npm.Name)
+	}
+
+	for _, tag := range p.settings.addTags {
+		logger := logrus.WithFields(logrus.Fields{
+			"name":    npm.Name,
+			"version": version,
+			"tag":     tag,
+		})
+
+		if packument.DistTags[tag] == version {
+			logger.Info("Dist-tag already points at the version")
+			continue
+		}
+		if p.settings.DryRun {
+			logger.Info("Dry run, not adding dist-tag")
+			continue
+		}
+
+		logger.Info("Adding dist-tag")
+		err := p.retry("dist-tag", func() error {
+			if p.settings.HTTPPublish {
+				return client.AddDistTag(p.context(), npm.Name, tag, version)
+			}
+			return p.runCommand(p.manager().distTagAddCommand(npm.Name, version, tag), npm.folder)
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("could not add dist-tag %s: %w", tag, err)
+		}
+	}
+
+	for _, tag := range p.settings.removeTags {
+		logger := logrus.WithFields(logrus.Fields{
+			"name": npm.Name,
+			"tag":  tag,
+		})
+
+		if _, found := packument.DistTags[tag]; !found {
+			logger.Info("Dist-tag not found, nothing to remove")
+			continue
+		}
+		if p.settings.DryRun {
+			logger.Info("Dry run, not removing dist-tag")
+			continue
+		}
+
+		logger.Info("Removing dist-tag")
+		err := p.retry("dist-tag", func() error {
+			if p.settings.HTTPPublish {
+				return client.RemoveDistTag(p.context(), npm.Name, tag)
+			}
+			return p.runCommand(p.manager().distTagRemoveCommand(npm.Name, tag), npm.folder)
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("could not remove dist-tag %s: %w", tag, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDistTags(t *testing.T) {
+	for _, test := range []struct {
+		add    string
+		remove string
+		valid  bool
+	}{
+		{add: "latest, stable", valid: true},
+		{remove: "next", valid: true},
+		{add: "latest", remove: "next,beta", valid: true},
+		{valid: false},
+		{add: " , ", valid: false},
+		{add: "1.5.0", valid: false},
+		{remove: "latest", valid: false},
+		{add: "next", remove: "next", valid: false},
+	} {
+		p := initPlugin()
+		p.settings.AddDistTags = test.add
+		p.settings.RemoveDistTags = test.remove
+
+		err := p.parseDistTags()
+		assert.Equal(t, test.valid, err == nil, "add %q remove %q", test.add, test.remove)
+	}
+
+	p := initPlugin()
+	p.settings.AddDistTags = "latest, stable"
+	p.settings.RemoveDistTags = "next"
+	if assert.Nil(t, p.parseDistTags()) {
+		assert.Equal(t, []string{"latest", "stable"}, p.settings.addTags)
+		assert.Equal(t, []string{"next"}, p.settings.removeTags)
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	p := initPlugin()
+	p.settings.SkipRegistryValidation = true
+	p.settings.Action = "owner"
+	assert.NotNil(t, p.Validate())
+
+	p.settings.Action = "dist-tag"
+	p.settings.AddDistTags = "latest"
+	p.settings.Workspaces = true
+	assert.NotNil(t, p.Validate())
+
+	p.settings.Workspaces = false
+	assert.Nil(t, p.Validate())
+}
+
+func TestValidateManagement(t *testing.T) {
+	p := initPlugin()
+	p.settings.Action = "dist-tag"
+	assert.Nil(t, p.validateManagement(true))
+
+	p.settings.Client = "yarn"
+	assert.Nil(t, p.validateManagement(true))
+	assert.NotNil(t, p.validateManagement(false))
+
+	p.settings.Client = ""
+	p.settings.OIDCToken = "id-token"
+	err := p.validateManagement(true)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "the dist-tag action requires credentials, an oidc token only allows publishing", err.Error())
+	}
+}
+
+func TestExecuteDistTag(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusOK, `"1.0.0": {}, "1.5.0": {}`)
+	p.settings.SkipWhoami = true
+	p.settings.Action = "dist-tag"
+	p.settings.AddDistTags = "latest"
+	p.settings.RemoveDistTags = "next"
+	p.settings.DistTagVersion = "1.5.0"
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stand-in registry lists no dist-tags, so only adding is needed
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, "npm dist-tag add my-awesome-package@1.5.0 latest", runner.commands[len(runner.commands)-1])
+		assert.NotContains(t, runner.commands, "npm publish")
+	}
+
+	p.settings.DistTagVersion = "2.0.0"
+	err := p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "version 2.0.0 of my-awesome-package is not published")
+	}
+}
+
+func TestExecuteDistTagAgainstRegistry(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.Tag = "next"
+	p.network.Client = server.Client()
+
+	if !assert.Nil(t, p.Validate()) || !assert.Nil(t, p.Execute()) {
+		return
+	}
+
+	// promote the version from next to latest
+	p.settings.Action = "dist-tag"
+	p.settings.AddDistTags = "latest"
+	p.settings.RemoveDistTags = "next"
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.Equal(t, map[string]string{"latest": "1.0.0"}, pkg.DistTags)
+	}
+
+	// a dry run leaves the dist-tags alone
+	p.settings.AddDistTags = "stable"
+	p.settings.RemoveDistTags = ""
+	p.settings.DryRun = true
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.Equal(t, map[string]string{"latest": "1.0.0"}, pkg.DistTags)
+	}
+}
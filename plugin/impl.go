@@ -6,52 +6,130 @@
 package plugin
 
 import (
-	"encoding/base64"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"os/exec"
-	"os/user"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/semver"
 	"github.com/sirupsen/logrus"
 )
 
 type (
 	// Settings for the Plugin.
 	Settings struct {
-		Username               string
-		Password               string
-		Token                  string
-		SkipWhoami             bool
-		Email                  string
-		Registry               string
-		Folder                 string
-		FailOnVersionConflict  bool
-		Tag                    string
-		Access                 string
-		SkipRegistryValidation bool
-
-		npm *npmPackage
+		Username                 string
+		Password                 string
+		Token                    string
+		SkipWhoami               bool
+		Email                    string
+		Registry                 string
+		Folder                   string
+		FailOnVersionConflict    bool
+		Tag                      string
+		Access                   string
+		SkipRegistryValidation   bool
+		HTTPPublish              bool
+		Workspaces               bool
+		DryRun                   bool
+		PrereleaseTags           string
+		DisallowPrereleaseLatest bool
+		VersionFromTag           bool
+		RewriteVersion           bool
+		Snapshot                 bool
+		SnapshotTag              string
+		Scopes                   string
+		MergeNpmrc               bool
+		Client                   string
+		OIDCToken                string
+		OIDCExchangeURL          string
+		CACert                   string
+		Proxy                    string
+		HTTPSProxy               string
+		NoProxy                  string
+		ClientCert               string
+		ClientKey                string
+		Retries                  int
+		RetryDelay               time.Duration
+		VerifyPublish            bool
+		VerifyTimeout            time.Duration
+		Action                   string
+		AddDistTags              string
+		RemoveDistTags           string
+		DistTagVersion           string
+		DeprecateRange           string
+		DeprecateMessage         string
+		UnpublishVersion         string
+		UnpublishForce           bool
+		RestoreLatest            bool
+		Tarball                  string
+		MaxUnpackedSize          string
+		MaxFiles                 int
+		ForbiddenFiles           string
+		RequiredFiles            string
+		RequireEntryPoints       bool
+
+		npm            *npmPackage
+		workspace      []*npmPackage
+		prereleaseTags map[string]string
+		scopes         map[string]scopeConfig
+		manager        packageManager
+		caCert         []byte
+		caFile         string
+		clientCert     []byte
+		clientKey      []byte
+		certFile       string
+		keyFile        string
+		addTags        []string
+		removeTags     []string
+		deprecateRange *semver.Range
+		deprecation    string
+		tarball        string
+		policy         *contentsPolicy
 	}
 
 	npmPackage struct {
-		Name    string    `json:"name"`
-		Version string    `json:"version"`
-		Config  npmConfig `json:"publishConfig"`
+		Name                 string            `json:"name"`
+		Version              string            `json:"version"`
+		Private              bool              `json:"private"`
+		Config               npmConfig         `json:"publishConfig"`
+		Dependencies         map[string]string `json:"dependencies"`
+		PeerDependencies     map[string]string `json:"peerDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+
+		folder         string
+		tag            string
+		rewriteVersion bool
+		integrity      string
+		access         string
 	}
 
 	npmConfig struct {
-		Registry string `json:"registry"`
+		Registry   string `json:"registry"`
+		Tag        string `json:"tag"`
+		Access     string `json:"access"`
+		Provenance bool   `json:"provenance"`
 	}
 )
 
 // globalRegistry defines the default NPM registry.
 const globalRegistry = "https://registry.npmjs.org/"
 
+// defaultSnapshotTag is the dist-tag and prerelease identifier of snapshots.
+const defaultSnapshotTag = "canary"
+
 // May be better as an enum in order to make it a const
 var defaultPortMap = map[string]string{
 	"http":  "80",
@@ -69,13 +147,19 @@ func isNilPortOrStandardSchemePort(u *url.URL) bool {
 }
 
 func (p *Plugin) CompareRegistries(nc npmConfig) (bool, error) {
-	parsedConfigReg, err := url.Parse(nc.Registry)
+	return compareRegistries(nc.Registry, p.settings.Registry)
+}
+
+// compareRegistries determines whether the two registry urls refer to the
+// same registry, treating an omitted port and the standard port as equal.
+func compareRegistries(configRegistry, settingsRegistry string) (bool, error) {
+	parsedConfigReg, err := url.Parse(configRegistry)
 	if err != nil {
-		return false, fmt.Errorf("package.json registry: %s failed to parse", nc.Registry)
+		return false, fmt.Errorf("package.json registry: %s failed to parse", configRegistry)
 	}
-	parsedSettingsReg, err := url.Parse(p.settings.Registry)
+	parsedSettingsReg, err := url.Parse(settingsRegistry)
 	if err != nil {
-		return false, fmt.Errorf("drone yaml npm Registry: %s failed to parse", p.settings.Registry)
+		return false, fmt.Errorf("drone yaml npm Registry: %s failed to parse", settingsRegistry)
 	}
 
 	ncDefaultOrNilPort := isNilPortOrStandardSchemePort(parsedConfigReg)
@@ -91,7 +175,14 @@ func (p *Plugin) CompareRegistries(nc npmConfig) (bool, error) {
 // Validate handles the settings validation of the plugin.
 func (p *Plugin) Validate() error {
 	// Check authentication options
-	if p.settings.Token == "" {
+	switch {
+	case p.settings.OIDCToken != "":
+		if p.settings.Token != "" {
+			return fmt.Errorf("token and oidc token cannot be combined")
+		}
+
+		logrus.Info("OIDC trusted publishing being used")
+	case p.settings.Token == "":
 		if p.settings.Username == "" {
 			return fmt.Errorf("no username provided")
 		}
@@ -106,21 +197,110 @@ func (p *Plugin) Validate() error {
 			"username": p.settings.Username,
 			"email":    p.settings.Email,
 		}).Info("Specified credentials")
-	} else {
+	default:
 		logrus.Info("Token credentials being used")
 	}
 
+	// Verify the same registry is being used
+	if p.settings.Registry == "" {
+		p.settings.Registry = globalRegistry
+	}
+	if p.settings.OIDCToken != "" && p.settings.OIDCExchangeURL == "" {
+		p.settings.OIDCExchangeURL = p.settings.Registry
+	}
+
+	if err := p.parsePrereleaseTags(); err != nil {
+		return err
+	}
+
+	if err := p.parseScopes(); err != nil {
+		return err
+	}
+
+	manager, err := newPackageManager(p.settings.Client)
+	if err != nil {
+		return err
+	}
+	if p.settings.Client != "" && p.settings.Client != npmClient {
+		if p.settings.HTTPPublish {
+			return fmt.Errorf("http publish does not use the %s client", p.settings.Client)
+		}
+		if p.settings.MergeNpmrc && p.settings.Client == yarnClient {
+			return fmt.Errorf("merging the npmrc is not supported by yarn")
+		}
+		if p.settings.NoProxy != "" && p.settings.Client == yarnClient {
+			return fmt.Errorf("noproxy is not supported by yarn")
+		}
+	}
+	p.settings.manager = manager
+
+	if err := p.configureNetwork(); err != nil {
+		return err
+	}
+
+	if err := p.validateAction(); err != nil {
+		return err
+	}
+
+	if p.settings.Snapshot {
+		if p.settings.VersionFromTag {
+			return fmt.Errorf("snapshot and version from tag cannot be combined")
+		}
+		if p.settings.SnapshotTag == "" {
+			p.settings.SnapshotTag = defaultSnapshotTag
+		}
+	}
+
+	if p.settings.Tarball != "" {
+		if err := p.validateTarball(); err != nil {
+			return err
+		}
+	}
+
+	if err := p.parsePolicy(); err != nil {
+		return err
+	}
+
+	if p.settings.Workspaces {
+		workspace, err := readWorkspaces(p.settings.Folder)
+		if err != nil {
+			return fmt.Errorf("invalid workspaces: %w", err)
+		}
+
+		for _, npm := range workspace {
+			if err := p.validatePackage(npm); err != nil {
+				return fmt.Errorf("%s: %w", npm.Name, err)
+			}
+		}
+
+		p.settings.workspace = workspace
+		return nil
+	}
+
 	// Verify package.json file
-	npm, err := readPackageFile(p.settings.Folder)
+	var npm *npmPackage
+	if p.settings.tarball != "" {
+		npm, err = readTarballPackage(p.settings.tarball, p.settings.Folder)
+	} else {
+		npm, err = readPackageFile(p.settings.Folder, p.publishing())
+	}
 	if err != nil {
 		return fmt.Errorf("invalid package.json: %w", err)
 	}
 
-	// Verify the same registry is being used
-	if p.settings.Registry == "" {
-		p.settings.Registry = globalRegistry
+	if err := p.validatePackage(npm); err != nil {
+		return err
 	}
 
+	p.settings.npm = npm
+	return nil
+}
+
+// validatePackage verifies the registry in the package's publishConfig is
+// the one specified in the settings and determines the dist-tag to publish
+// the package with. Actions managing published versions only need the
+// registry and scope, the rest only matters when publishing.
+func (p *Plugin) validatePackage(npm *npmPackage) error {
 	registriesMatch, err := p.CompareRegistries(npm.Config)
 	if err != nil {
 		return fmt.Errorf(
@@ -133,12 +313,55 @@ func (p *Plugin) Validate() error {
 		return fmt.Errorf("registry values do not match .drone.yml: %s package.json: %s", p.settings.Registry, npm.Config.Registry)
 	}
 
-	p.settings.npm = npm
+	if err := p.validateScope(npm); err != nil {
+		return err
+	}
+
+	if !p.publishing() {
+		return nil
+	}
+
+	if err := p.ComparePublishConfig(npm.Config); err != nil {
+		return err
+	}
+
+	switch {
+	case p.settings.Snapshot:
+		if err := p.applySnapshotVersion(npm); err != nil {
+			return err
+		}
+	case p.settings.VersionFromTag:
+		if err := p.applyTagVersion(npm); err != nil {
+			return err
+		}
+	}
+
+	// yarn can only publish from the folder
+	if npm.rewriteVersion && p.settings.Client == yarnClient && !p.settings.HTTPPublish {
+		return fmt.Errorf("replacing the version is not supported by yarn")
+	}
+
+	tag, err := p.distTag(npm)
+	if err != nil {
+		return err
+	}
+	npm.tag = tag
+
+	p.applyPublishConfig(npm)
+
+	if p.settings.policy != nil {
+		if err := p.checkContents(npm); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // Execute provides the implementation of the plugin.
 func (p *Plugin) Execute() error {
+	defer p.cleanup()
+
 	// Write the npmrc file
 	if err := p.writeNpmrc(); err != nil {
 		return fmt.Errorf("could not create npmrc: %w", err)
@@ -149,165 +372,398 @@ func (p *Plugin) Execute() error {
 		return fmt.Errorf("could not authenticate: %w", err)
 	}
 
+	switch p.settings.Action {
+	case distTagAction:
+		return p.manageDistTags(p.settings.npm)
+	case deprecateAction, undeprecateAction:
+		return p.manageDeprecations(p.settings.npm)
+	case unpublishAction:
+		return p.manageUnpublish(p.settings.npm)
+	}
+
+	if p.settings.Workspaces {
+		return p.publishWorkspace()
+	}
+
+	_, err := p.publishPackage(p.settings.npm)
+	return err
+}
+
+// publishPackage publishes a single package if it should be published,
+// reporting whether it was.
+func (p *Plugin) publishPackage(npm *npmPackage) (bool, error) {
 	// Determine whether to publish
-	publish, err := p.shouldPublishPackage()
+	publish, err := p.shouldPublishPackage(npm)
 
 	if err != nil {
-		return fmt.Errorf("could not determine if package should be published: %w", err)
+		return false, fmt.Errorf("could not determine if package should be published: %w", err)
 	}
 
-	if publish {
-		logrus.Info("Publishing package")
-		if err = runCommand(publishCommand(&p.settings), p.settings.Folder); err != nil {
-			return fmt.Errorf("could not publish package: %w", err)
-		}
-	} else {
+	if !publish {
 		logrus.Info("Not publishing package")
+		return false, nil
 	}
 
-	return nil
+	if err = p.exchangeOIDCToken(npm); err != nil {
+		return false, fmt.Errorf("could not exchange oidc token: %w", err)
+	}
+
+	logrus.Info("Publishing package")
+	if err = p.publish(npm); err != nil {
+		return false, fmt.Errorf("could not publish package: %w", err)
+	}
+
+	if p.settings.VerifyPublish && !p.settings.DryRun {
+		if err = p.verifyPublished(npm); err != nil {
+			return false, fmt.Errorf("could not verify package: %w", err)
+		}
+	}
+
+	return true, nil
 }
 
-// / writeNpmrc creates a .npmrc in the folder for authentication
+// / writeNpmrc creates the private credential file of the client, an npmrc
+// unless publishing with yarn, in a temporary directory for authentication,
+// leaving the configuration of the user untouched.
 func (p *Plugin) writeNpmrc() error {
-	var f func(settings *Settings) string
-	if p.settings.Token == "" {
+	switch {
+	case p.settings.Token != "":
+		logrus.Info("Token credentials being used")
+	case p.settings.OIDCToken != "":
+		logrus.Info("No credentials until the oidc token is exchanged")
+	default:
 		logrus.WithFields(logrus.Fields{
 			"username": p.settings.Username,
 			"email":    p.settings.Email,
 		}).Info("Specified credentials")
-		f = npmrcContentsUsernamePassword
-	} else {
-		logrus.Info("Token credentials being used")
-		f = npmrcContentsToken
 	}
 
-	// write npmrc file
-	home := "/root"
-	currentUser, err := user.Current()
-	if err == nil {
-		home = currentUser.HomeDir
+	if err := p.writeCertificates(); err != nil {
+		return err
+	}
+
+	contents, err := p.manager().configContents(&p.settings, p.network.SkipVerify)
+	if err != nil {
+		return err
+	}
+
+	// merge the existing npmrc, entries written later take precedence
+	if p.settings.MergeNpmrc {
+		existing, err := readUserNpmrc()
+		if err != nil {
+			return err
+		}
+		contents = existing + "\n" + contents
+	}
+
+	dir, err := p.tempDir()
+	if err != nil {
+		return err
+	}
+	npmrcPath := filepath.Join(dir, p.manager().configFile())
+
+	logrus.WithField("path", npmrcPath).Info("Writing credentials")
+	p.npmrc = npmrcPath
+
+	return os.WriteFile(npmrcPath, []byte(contents), 0600) //nolint:gomnd
+}
+
+// readUserNpmrc reads the npmrc in the home directory of the user.
+func readUserNpmrc() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "/root"
 	}
 	npmrcPath := path.Join(home, ".npmrc")
 
-	logrus.WithField("path", npmrcPath).Info("Writing npmrc")
+	contents, err := os.ReadFile(npmrcPath)
+	if os.IsNotExist(err) {
+		logrus.WithField("path", npmrcPath).Info("No existing npmrc to merge")
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read npmrc at %s: %w", npmrcPath, err)
+	}
+
+	logrus.WithField("path", npmrcPath).Info("Merging existing npmrc")
+
+	return strings.TrimRight(string(contents), "\n"), nil
+}
+
+// tempDir returns the private temporary directory for files written during
+// Execute, creating it on first use.
+func (p *Plugin) tempDir() (string, error) {
+	if p.temp != "" {
+		return p.temp, nil
+	}
+
+	dir, err := os.MkdirTemp("", "drone-npm-")
+	if err != nil {
+		return "", fmt.Errorf("could not create temporary directory: %w", err)
+	}
+
+	p.temp = dir
+	return dir, nil
+}
+
+// cleanup removes the temporary files written during Execute.
+func (p *Plugin) cleanup() {
+	if p.temp == "" {
+		return
+	}
+
+	if err := os.RemoveAll(p.temp); err != nil {
+		logrus.WithError(err).WithField("path", p.temp).Warn("Could not remove temporary files")
+	}
 
-	return os.WriteFile(npmrcPath, []byte(f(&p.settings)), 0644) //nolint:gomnd
+	p.temp = ""
+	p.npmrc = ""
+	p.settings.caFile = ""
+	p.settings.certFile = ""
+	p.settings.keyFile = ""
 }
 
 // / shouldPublishPackage determines if the package should be published
-func (p *Plugin) shouldPublishPackage() (bool, error) {
-	cmd := packageVersionsCommand(p.settings.npm.Name)
-	cmd.Dir = p.settings.Folder
+func (p *Plugin) shouldPublishPackage(npm *npmPackage) (bool, error) {
+	if p.settings.Snapshot {
+		logrus.WithField("version", npm.Version).Info("Snapshot versions are unique, skipping version check")
+		return true, nil
+	}
 
-	trace(cmd)
-	out, err := cmd.CombinedOutput()
+	client, err := p.registryClient()
+	if err != nil {
+		return false, err
+	}
 
-	// see if there was an error
-	// if there is an error its likely due to the package never being published
-	if err == nil {
-		// parse the json output
-		var versions []string
-		err = json.Unmarshal(out, &versions)
+	logrus.WithFields(logrus.Fields{
+		"name":     npm.Name,
+		"registry": p.settings.Registry,
+	}).Debug("Looking up package versions")
 
-		if err != nil {
-			logrus.Debug("Could not parse into array of string. Likely single value")
+	var packument *registry.Packument
+	err = p.retry("lookup", func() (err error) {
+		packument, err = client.Packument(p.context(), npm.Name)
+		return err
+	}, nil)
+	if errors.Is(err, registry.ErrNotFound) {
+		logrus.Info("Name was not found in the registry")
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not retrieve package versions: %w", err)
+	}
+
+	for value := range packument.Versions {
+		logrus.WithField("version", value).Debug("Found version of package")
+	}
+
+	if _, found := packument.Versions[npm.Version]; found {
+		logrus.Info("Version found in the registry")
+		if p.settings.FailOnVersionConflict {
+			return false, fmt.Errorf("cannot publish package due to version conflict")
+		}
+		return false, nil
+	}
+
+	logrus.Info("Version not found in the registry")
+
+	return true, nil
+}
+
+// registryClient creates a client for the configured registry using the
+// credentials from the settings.
+func (p *Plugin) registryClient() (*registry.Client, error) {
+	return registry.New(p.settings.Registry, registry.Auth{
+		Token:    p.settings.Token,
+		Username: p.settings.Username,
+		Password: p.settings.Password,
+	}, p.network.Client)
+}
 
-			var version string
-			err := json.Unmarshal(out, &version)
+// context returns the context for requests made by the plugin.
+func (p *Plugin) context() context.Context {
+	if p.network.Context == nil {
+		return context.Background()
+	}
+
+	return p.network.Context
+}
 
+// publish publishes the package either through the npm CLI or directly
+// over HTTP.
+func (p *Plugin) publish(npm *npmPackage) error {
+	if !p.settings.HTTPPublish {
+		// A replaced version is published from a tarball packed by the client
+		settings := p.settings
+		if npm.rewriteVersion {
+			dir, err := os.MkdirTemp("", "drone-npm-")
 			if err != nil {
-				return false, err
+				return err
 			}
+			defer os.RemoveAll(dir)
 
-			versions = append(versions, version)
+			if settings.tarball, err = p.stageVersion(npm, dir); err != nil {
+				return err
+			}
 		}
 
-		for _, value := range versions {
-			logrus.WithField("version", value).Debug("Found version of package")
+		return p.retry("publish", func() error {
+			output, err := p.runCommandOutput(p.manager().publishCommand(&settings, npm.tag), npm.folder)
+			if err != nil {
+				return err
+			}
 
-			if p.settings.npm.Version == value {
-				logrus.Info("Version found in the registry")
-				if p.settings.FailOnVersionConflict {
-					return false, fmt.Errorf("cannot publish package due to version conflict")
-				}
-				return false, nil
+			// the client reports the integrity of the tarball it packed
+			if npm.integrity == "" {
+				npm.integrity = publishedIntegrity(output)
 			}
+
+			return nil
+		}, p.publishedBeforeRetry(npm))
+	}
+
+	tarball, err := p.packPackage(npm)
+	if err != nil {
+		return fmt.Errorf("could not pack package: %w", err)
+	}
+	if npm.rewriteVersion {
+		if err := setTarballVersion(tarball, npm.Version); err != nil {
+			return err
 		}
+	}
 
-		logrus.Info("Version not found in the registry")
-	} else {
-		logrus.Info("Name was not found in the registry")
+	client, err := p.registryClient()
+	if err != nil {
+		return err
 	}
 
-	return true, nil
+	if p.settings.DryRun {
+		printTarball(tarball)
+		logrus.Info("Dry run, not uploading package tarball")
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":      tarball.Name,
+		"version":   tarball.Version,
+		"files":     len(tarball.Files),
+		"size":      len(tarball.Data),
+		"integrity": tarball.Integrity,
+		"registry":  p.settings.Registry,
+	}).Info("Uploading package tarball")
+	npm.integrity = tarball.Integrity
+
+	return p.retry("publish", func() error {
+		return client.Publish(p.context(), tarball.Manifest, tarball.Data, npm.tag, npm.access)
+	}, p.publishedBeforeRetry(npm))
+}
+
+// publishedBeforeRetry checks whether a failed publish went through before
+// it is retried, unless publishing as a dry run.
+func (p *Plugin) publishedBeforeRetry(npm *npmPackage) func() (bool, error) {
+	if p.settings.DryRun {
+		return nil
+	}
+
+	return func() (bool, error) {
+		return p.versionPublished(npm)
+	}
 }
 
 // / authenticate atempts to authenticate with the NPM registry.
 func (p *Plugin) authenticate() error {
-	var cmds []*exec.Cmd
+	if p.settings.HTTPPublish {
+		return p.authenticateHTTP()
+	}
 
-	// Write the version command
-	cmds = append(cmds, versionCommand())
+	manager := p.manager()
 
-	// write registry command
-	if p.settings.Registry != globalRegistry {
-		cmds = append(cmds, registryCommand(p.settings.Registry))
+	// Run the version command, the registry is configured by the npmrc
+	if err := p.runCommand(manager.versionCommand(), p.settings.Folder); err != nil {
+		return err
 	}
 
-	// Write skip verify command
-	if p.network.SkipVerify {
-		cmds = append(cmds, skipVerifyCommand())
+	// Run whoami command to verify credentials, an oidc token is only
+	// exchanged for credentials when publishing
+	if p.settings.SkipWhoami || p.settings.OIDCToken != "" {
+		return nil
 	}
 
-	// Write whoami command to verify credentials
-	if !p.settings.SkipWhoami {
-		cmds = append(cmds, whoamiCommand())
+	return p.retry("whoami", func() error {
+		return p.runCommand(manager.whoamiCommand(), p.settings.Folder)
+	}, nil)
+}
+
+// authenticateHTTP verifies the credentials against the registry without
+// requiring the npm CLI.
+func (p *Plugin) authenticateHTTP() error {
+	if p.settings.SkipWhoami || p.settings.OIDCToken != "" {
+		return nil
 	}
 
-	// Run commands
-	err := runCommands(cmds, p.settings.Folder)
+	client, err := p.registryClient()
+	if err != nil {
+		return err
+	}
 
+	var username string
+	err = p.retry("whoami", func() (err error) {
+		username, err = client.Whoami(p.context())
+		return err
+	}, nil)
 	if err != nil {
 		return err
 	}
 
+	logrus.WithField("username", username).Info("Authenticated with the registry")
+
 	return nil
 }
 
-// / readPackageFile reads the package file at the given path.
-func readPackageFile(folder string) (*npmPackage, error) {
+// readPackageFile reads the package file at the given path, listing every
+// problem which prevents publishing the package. Actions which don't publish
+// only need a valid name and version.
+func readPackageFile(folder string, publishing bool) (*npmPackage, error) {
+	npm, file, err := parsePackageFile(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPackageFile(npm, file, publishing); err != nil {
+		return nil, err
+	}
+
+	return npm, nil
+}
+
+// parsePackageFile reads the package file in the folder without validating
+// its fields, returning the contents along with the package.
+func parsePackageFile(folder string) (*npmPackage, []byte, error) {
 	// Verify package.json file exists
 	packagePath := path.Join(folder, "package.json")
 	info, err := os.Stat(packagePath)
 
 	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("no package.json at %s: %w", packagePath, err)
+		return nil, nil, fmt.Errorf("no package.json at %s: %w", packagePath, err)
 	}
 	if info.IsDir() {
-		return nil, fmt.Errorf("the package.json at %s is a directory", packagePath)
+		return nil, nil, fmt.Errorf("the package.json at %s is a directory", packagePath)
 	}
 
 	// Read the file
 	file, err := os.ReadFile(packagePath)
 	if err != nil {
-		return nil, fmt.Errorf("could not read package.json at %s: %w", packagePath, err)
+		return nil, nil, fmt.Errorf("could not read package.json at %s: %w", packagePath, err)
 	}
 
 	// Unmarshal the json data
 	npm := npmPackage{}
 	err = json.Unmarshal(file, &npm)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Make sure values are present
-	if npm.Name == "" {
-		return nil, fmt.Errorf("no package name present")
-	}
-	if npm.Version == "" {
-		return nil, fmt.Errorf("no package version present")
-	}
+	npm.folder = folder
 
 	// Set the default registry
 	if npm.Config.Registry == "" {
@@ -320,23 +776,51 @@ func readPackageFile(folder string) (*npmPackage, error) {
 		"path":    packagePath,
 	}).Info("Found package.json")
 
-	return &npm, nil
+	return &npm, file, nil
+}
+
+// checkPackageFile verifies the fields of the package and the files they
+// point at, reporting all problems at once. Private packages and missing
+// files only matter when publishing.
+func checkPackageFile(npm *npmPackage, file []byte, publishing bool) error {
+	problems := identityProblems(npm)
+	if publishing {
+		problems = append(packageProblems(npm), targetProblems(npm.folder, file)...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("problems in %s:\n  %s", path.Join(npm.folder, "package.json"), strings.Join(problems, "\n  "))
 }
 
 // npmrcContentsUsernamePassword creates the contents from a username and
 // password
 func npmrcContentsUsernamePassword(config *Settings) string {
 	// get the base64 encoded string
-	authString := fmt.Sprintf("%s:%s", config.Username, config.Password)
-	encoded := base64.StdEncoding.EncodeToString([]byte(authString))
+	encoded := basicAuth(config.Username, config.Password)
 
 	// create the file contents
 	return fmt.Sprintf("_auth = %s\nemail = %s", encoded, config.Email)
 }
 
 // / Writes npmrc contents when using a token
-func npmrcContentsToken(config *Settings) string {
-	registry, _ := url.Parse(config.Registry)
+func npmrcContentsToken(config *Settings) (string, error) {
+	nerfDart, err := registryNerfDart(config.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:_authToken=%s", nerfDart, config.Token), nil
+}
+
+// registryNerfDart returns the protocol relative registry url npm uses to
+// scope credentials to a registry.
+func registryNerfDart(registryURL string) (string, error) {
+	registry, err := url.Parse(registryURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid registry %s: %w", registryURL, err)
+	}
 	registry.Scheme = "" // Reset the scheme to empty. This makes it so we will get a protocol relative URL.
 	host, port, _ := net.SplitHostPort(registry.Host)
 	if port == "80" || port == "443" {
@@ -347,73 +831,64 @@ func npmrcContentsToken(config *Settings) string {
 	if !strings.HasSuffix(registryString, "/") {
 		registryString += "/"
 	}
-	return fmt.Sprintf("%s:_authToken=%s", registryString, config.Token)
-}
-
-// versionCommand gets the npm version
-func versionCommand() *exec.Cmd {
-	return exec.Command("npm", "--version")
-}
-
-// registryCommand sets the NPM registry.
-func registryCommand(registry string) *exec.Cmd {
-	return exec.Command("npm", "config", "set", "registry", registry)
-}
-
-// skipVerifyCommand disables ssl verification.
-func skipVerifyCommand() *exec.Cmd {
-	return exec.Command("npm", "config", "set", "strict-ssl", "false")
+	return registryString, nil
 }
 
-// whoamiCommand creates a command that gets the currently logged in user.
-func whoamiCommand() *exec.Cmd {
-	return exec.Command("npm", "whoami")
-}
-
-// packageVersionsCommand gets the versions of the npm package.
-func packageVersionsCommand(name string) *exec.Cmd {
-	return exec.Command("npm", "view", name, "versions", "--json")
+// trace writes each command to standard error (preceded by a ‘$ ’) before it
+// is executed. Used for debugging your build. Credentials are masked.
+func trace(cmd *exec.Cmd, r *redactor) {
+	fmt.Fprintf(os.Stdout, "+ %s\n", r.Redact(strings.Join(cmd.Args, " ")))
 }
 
-// publishCommand runs the publish command
-func publishCommand(settings *Settings) *exec.Cmd {
-	commandArgs := []string{"publish"}
-
-	if settings.Tag != "" {
-		commandArgs = append(commandArgs, "--tag", settings.Tag)
-	}
-
-	if settings.Access != "" {
-		commandArgs = append(commandArgs, "--access", settings.Access)
-	}
-
-	return exec.Command("npm", commandArgs...)
+// printTarball writes the contents and details of a packed tarball to
+// standard out.
+func printTarball(tarball *pack.Tarball) {
+	fmt.Fprintf(os.Stdout, "Tarball Contents\n")
+	for _, file := range tarball.Files {
+		fmt.Fprintf(os.Stdout, "%8d %s\n", file.Size, file.Path)
+	}
+
+	fmt.Fprintf(os.Stdout, "Tarball Details\n")
+	fmt.Fprintf(os.Stdout, "name:          %s\n", tarball.Name)
+	fmt.Fprintf(os.Stdout, "version:       %s\n", tarball.Version)
+	fmt.Fprintf(os.Stdout, "package size:  %d\n", len(tarball.Data))
+	fmt.Fprintf(os.Stdout, "unpacked size: %d\n", tarball.UnpackedSize)
+	fmt.Fprintf(os.Stdout, "shasum:        %s\n", tarball.Shasum)
+	fmt.Fprintf(os.Stdout, "integrity:     %s\n", tarball.Integrity)
+	fmt.Fprintf(os.Stdout, "total files:   %d\n", len(tarball.Files))
 }
 
-// trace writes each command to standard error (preceded by a ‘$ ’) before it
-// is executed. Used for debugging your build.
-func trace(cmd *exec.Cmd) {
-	fmt.Fprintf(os.Stdout, "+ %s\n", strings.Join(cmd.Args, " "))
+// runCommand executes the cmd in the given directory using the written
+// credential file, adding the environment of the cmd to the one inherited.
+// Credentials are masked in the output of the command.
+func (p *Plugin) runCommand(cmd *exec.Cmd, dir string) error {
+	_, err := p.runCommandOutput(cmd, dir)
+	return err
 }
 
-// runCommands executes the list of cmds in the given directory.
-func runCommands(cmds []*exec.Cmd, dir string) error {
-	for _, cmd := range cmds {
-		err := runCommand(cmd, dir)
+// runCommandOutput executes the cmd like runCommand, also returning what it
+// wrote to standard out.
+func (p *Plugin) runCommandOutput(cmd *exec.Cmd, dir string) ([]byte, error) {
+	r := p.redactor()
+	stdout := newRedactWriter(os.Stdout, r)
+	stderr := newRedactWriter(os.Stderr, r)
 
-		if err != nil {
-			return err
+	var output bytes.Buffer
+	cmd.Stdout = io.MultiWriter(stdout, &output)
+	cmd.Stderr = stderr
+	cmd.Dir = dir
+	if p.npmrc != "" || cmd.Env != nil {
+		env := append(os.Environ(), cmd.Env...)
+		if p.npmrc != "" {
+			env = append(env, p.manager().configEnv(&p.settings, p.npmrc, p.network.SkipVerify)...)
 		}
+		cmd.Env = env
 	}
+	trace(cmd, r)
 
-	return nil
-}
-
-func runCommand(cmd *exec.Cmd, dir string) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Dir = dir
-	trace(cmd)
+	err := p.runner.Run(cmd)
+	stdout.Flush() //nolint:errcheck
+	stderr.Flush() //nolint:errcheck
 
-	return cmd.Run()
+	return output.Bytes(), err
 }
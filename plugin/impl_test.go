@@ -7,9 +7,17 @@ package plugin
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
 	"github.com/drone-plugins/drone-plugin-lib/drone"
 	"github.com/stretchr/testify/assert"
 )
@@ -69,6 +77,7 @@ func initPlugin() *Plugin {
 		settings: initFakeSettings(),
 		pipeline: initFakePipeline(),
 		network:  initFakeNetwork(),
+		runner:   &fakeRunner{},
 	}
 }
 
@@ -239,6 +248,279 @@ func TestValidateWithRegistryVariations(t *testing.T) {
 	assert.Nil(t, skipWeirdPortErr)
 }
 
+func TestShouldPublishPackage(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"name": "Test Package", "versions": {"1.0.0": {}, "1.33.7": {}}}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	p := initPlugin()
+	p.settings.Registry = server.URL
+	p.network.Client = server.Client()
+
+	// Version already published
+	publish, err := p.shouldPublishPackage(p.settings.npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "version conflict")
+	}
+	assert.Equal(t, false, publish)
+
+	p.settings.FailOnVersionConflict = false
+	publish, err = p.shouldPublishPackage(p.settings.npm)
+	assert.Nil(t, err)
+	assert.Equal(t, false, publish)
+
+	// New version
+	p.settings.npm.Version = "1.33.8"
+	publish, err = p.shouldPublishPackage(p.settings.npm)
+	assert.Nil(t, err)
+	assert.Equal(t, true, publish)
+
+	// Package never published
+	status = http.StatusNotFound
+	publish, err = p.shouldPublishPackage(p.settings.npm)
+	assert.Nil(t, err)
+	assert.Equal(t, true, publish)
+
+	// Registry failures must not turn into a publish
+	status = http.StatusUnauthorized
+	publish, err = p.shouldPublishPackage(p.settings.npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "401")
+	}
+	assert.Equal(t, false, publish)
+
+	status = http.StatusBadGateway
+	publish, err = p.shouldPublishPackage(p.settings.npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "502")
+	}
+	assert.Equal(t, false, publish)
+}
+
+func TestExecuteDryRun(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/-/whoami" {
+			w.Write([]byte(`{"username": "drone"}`)) //nolint:errcheck
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.DryRun = true
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) {
+		assert.Nil(t, p.Execute())
+		assert.Equal(t, []string{"GET /-/whoami", "GET /my-awesome-package"}, methods)
+	}
+}
+
+func TestWriteNpmrc(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	existing := filepath.Join(home, ".npmrc")
+	if err := os.WriteFile(existing, []byte("save-exact=true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.MergeNpmrc = true
+
+	if assert.Nil(t, p.writeNpmrc()) {
+		npmrc := p.npmrc
+		assert.NotEqual(t, existing, npmrc)
+
+		info, err := os.Stat(npmrc)
+		if assert.Nil(t, err) {
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+
+		contents, _ := os.ReadFile(npmrc)
+		assert.Equal(t, "save-exact=true\n//fakenpm.reg.org/good/path/:_authToken=token"+
+			"\nregistry=https://fakenpm.reg.org/good/path"+
+			"\nstrict-ssl=false", string(contents))
+
+		// the existing npmrc is left untouched
+		contents, _ = os.ReadFile(existing)
+		assert.Equal(t, "save-exact=true\n", string(contents))
+
+		p.cleanup()
+		_, err = os.Stat(npmrc)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+// initExecutePlugin creates a plugin publishing with a fake runner against a
+// stand-in registry which has published the given versions.
+func initExecutePlugin(t *testing.T, status int, versions string) (*Plugin, *fakeRunner) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"name": "my-awesome-package", "versions": {%s}}`, versions)
+	}))
+	t.Cleanup(server.Close)
+
+	runner := &fakeRunner{}
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.network.Client = server.Client()
+	p.runner = runner
+
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p, runner
+}
+
 func TestExecute(t *testing.T) {
-	t.Skip()
+	p, runner := initExecutePlugin(t, http.StatusOK, `"0.9.0": {}`)
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"npm --version",
+			"npm whoami",
+			"npm publish",
+		}, runner.commands)
+		assert.Equal(t, "__test__", runner.dirs[2])
+
+		// every command uses the generated npmrc
+		for _, env := range runner.envs {
+			assert.Contains(t, strings.Join(env, "\n"), "NPM_CONFIG_USERCONFIG=")
+		}
+	}
+}
+
+func TestExecuteNeverPublished(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusNotFound, "")
+	p.settings.SkipWhoami = true
+	p.settings.Access = "public"
+	p.network.SkipVerify = false
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"npm --version",
+			"npm publish --access public",
+		}, runner.commands)
+	}
+}
+
+func TestExecuteVersionExists(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusOK, `"1.0.0": {}`)
+	p.settings.FailOnVersionConflict = false
+
+	if assert.Nil(t, p.Execute()) {
+		assert.NotContains(t, runner.commands, "npm publish")
+	}
+
+	p.settings.FailOnVersionConflict = true
+	err := p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "version conflict")
+	}
+}
+
+func TestExecuteFailures(t *testing.T) {
+	// Authentication failure
+	p, runner := initExecutePlugin(t, http.StatusNotFound, "")
+	runner.fail("npm whoami")
+	err := p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "could not authenticate")
+	}
+	assert.NotContains(t, runner.commands, "npm publish")
+
+	// Registry failure
+	p, runner = initExecutePlugin(t, http.StatusServiceUnavailable, "")
+	err = p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "503")
+	}
+	assert.NotContains(t, runner.commands, "npm publish")
+
+	// Publish failure
+	p, runner = initExecutePlugin(t, http.StatusNotFound, "")
+	runner.fail("npm publish")
+	err = p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "could not publish package")
+	}
+}
+
+func TestExecuteAgainstRegistry(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	server.AddUser("hubot", "secret")
+
+	p := initPlugin()
+	p.settings.Username = ""
+	p.settings.Password = ""
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.Tag = "next"
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, ok := server.Packument("my-awesome-package")
+		if assert.True(t, ok) {
+			assert.Equal(t, map[string]string{"next": "1.0.0"}, pkg.DistTags)
+			assert.Contains(t, pkg.Versions, "1.0.0")
+		}
+
+		tarball, _ := server.Tarball("my-awesome-package", "1.0.0")
+		packed, err := pack.Pack("__test__")
+		if assert.Nil(t, err) {
+			assert.Equal(t, packed.Data, tarball)
+		}
+
+		assert.Equal(t, []string{
+			"GET /-/whoami",
+			"GET /my-awesome-package",
+			"PUT /my-awesome-package",
+		}, server.Requests())
+	}
+
+	// the version now exists so publishing again is a conflict
+	err := p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "version conflict")
+	}
+
+	// authenticating with a username and password
+	p = initPlugin()
+	p.settings.Username = "hubot"
+	p.settings.Password = "wrong"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) {
+		err = p.Execute()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "401")
+		}
+
+		p.settings.Password = "secret"
+		p.settings.FailOnVersionConflict = false
+		assert.Nil(t, p.Execute())
+	}
 }
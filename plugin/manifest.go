@@ -0,0 +1,234 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/semver"
+)
+
+// maxNameLength is the longest package name the registry accepts.
+const maxNameLength = 214
+
+var (
+	// scopedNameRegexp matches a scoped package name.
+	scopedNameRegexp = regexp.MustCompile(`^@([^/]*)/([^/]*)$`)
+
+	// namePartRegexp matches the url-safe characters of the scope and name
+	// of a package.
+	namePartRegexp = regexp.MustCompile(`^[a-z0-9._~-]+$`)
+)
+
+// packageTargets are the fields of package.json pointing at files of the
+// package.
+type packageTargets struct {
+	Main    string          `json:"main"`
+	Module  string          `json:"module"`
+	Types   string          `json:"types"`
+	Typings string          `json:"typings"`
+	Bin     json.RawMessage `json:"bin"`
+	Exports json.RawMessage `json:"exports"`
+}
+
+// packageProblems lists the problems with the name, version and private
+// fields of the package which prevent publishing it.
+func packageProblems(npm *npmPackage) []string {
+	problems := identityProblems(npm)
+
+	if npm.Private {
+		problems = append(problems, "package is private")
+	}
+
+	return problems
+}
+
+// identityProblems lists the problems with the name and version of the
+// package, which every action needs to find the published versions.
+func identityProblems(npm *npmPackage) []string {
+	problems := nameProblems(npm.Name)
+
+	if npm.Version == "" {
+		problems = append(problems, "no package version present")
+	} else if _, err := semver.Parse(npm.Version); err != nil {
+		problems = append(problems, fmt.Sprintf("version %q is not a valid semantic version", npm.Version))
+	}
+
+	return problems
+}
+
+// nameProblems checks the name against the naming rules of npm.
+func nameProblems(name string) []string {
+	if name == "" {
+		return []string{"no package name present"}
+	}
+
+	var problems []string
+	if len(name) > maxNameLength {
+		problems = append(problems, fmt.Sprintf("name can't be longer than %d characters", maxNameLength))
+	}
+	if strings.ToLower(name) != name {
+		problems = append(problems, "name can't contain capital letters")
+	}
+
+	part := strings.ToLower(name)
+	if strings.HasPrefix(part, "@") {
+		match := scopedNameRegexp.FindStringSubmatch(part)
+		if match == nil || match[1] == "" || match[2] == "" {
+			return append(problems, fmt.Sprintf("scoped name %s must be formatted as @scope/name", name))
+		}
+		if !namePartRegexp.MatchString(match[1]) {
+			problems = append(problems, "scope can only contain url-safe characters")
+		}
+		part = match[2]
+	}
+
+	if strings.HasPrefix(part, ".") || strings.HasPrefix(part, "_") {
+		problems = append(problems, "name can't start with a period or underscore")
+	}
+	if !namePartRegexp.MatchString(part) {
+		problems = append(problems, "name can only contain url-safe characters")
+	}
+
+	return problems
+}
+
+// targetProblems lists the files package.json points at which don't exist
+// in the folder. The main entry point is resolved like node does, allowing
+// the extension or index file to be left out, and wildcard exports are not
+// checked.
+func targetProblems(folder string, data []byte) []string {
+	targets := packageTargets{}
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return []string{fmt.Sprintf("could not read the targets: %s", err)}
+	}
+
+	exists := func(target string) bool {
+		entry := pack.CleanPath(target)
+		info, err := os.Stat(filepath.Join(folder, filepath.FromSlash(entry)))
+		return err == nil && !info.IsDir()
+	}
+
+	var problems []string
+	if targets.Main != "" && !mainExists(targets.Main, exists) {
+		problems = append(problems, fmt.Sprintf("main %s does not exist", targets.Main))
+	}
+	for _, field := range []struct{ name, target string }{
+		{"module", targets.Module},
+		{"types", targets.Types},
+		{"typings", targets.Typings},
+	} {
+		if field.target != "" && !exists(field.target) {
+			problems = append(problems, fmt.Sprintf("%s %s does not exist", field.name, field.target))
+		}
+	}
+
+	bins, err := binTargets(targets.Bin)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, target := range bins {
+		if !exists(target) {
+			problems = append(problems, fmt.Sprintf("bin %s does not exist", target))
+		}
+	}
+
+	exports, err := exportTargets(targets.Exports)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, target := range exports {
+		if !strings.Contains(target, "*") && !exists(target) {
+			problems = append(problems, fmt.Sprintf("export %s does not exist", target))
+		}
+	}
+
+	return problems
+}
+
+// mainExists resolves the main entry point with the extensions and index
+// file node tries.
+func mainExists(main string, exists func(string) bool) bool {
+	entry := pack.CleanPath(main)
+	for _, candidate := range []string{entry, entry + ".js", entry + ".json", entry + "/index.js"} {
+		if exists(candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// binTargets returns the files of the bin field, which is either a single
+// file or a map of commands to files.
+func binTargets(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	commands := map[string]string{}
+	if err := json.Unmarshal(raw, &commands); err != nil {
+		return nil, fmt.Errorf("bin must be a file or a map of commands to files")
+	}
+
+	var targets []string
+	for _, target := range commands {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	return targets, nil
+}
+
+// exportTargets returns the files of the exports field, walking the nested
+// subpaths, conditions and fallback arrays.
+func exportTargets(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var exports interface{}
+	if err := json.Unmarshal(raw, &exports); err != nil {
+		return nil, fmt.Errorf("exports is not valid: %w", err)
+	}
+
+	var targets []string
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			targets = append(targets, v)
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key])
+			}
+		}
+	}
+	walk(exports)
+
+	return targets, nil
+}
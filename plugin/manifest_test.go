@@ -0,0 +1,128 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameProblems(t *testing.T) {
+	for _, name := range []string{"my-package", "@acme/my-package", "my.package_2", "~tilde"} {
+		assert.Empty(t, nameProblems(name), name)
+	}
+
+	tests := map[string][]string{
+		"":                       {"no package name present"},
+		"My-Package":             {"name can't contain capital letters"},
+		".hidden":                {"name can't start with a period or underscore"},
+		"_private":               {"name can't start with a period or underscore"},
+		"my package":             {"name can only contain url-safe characters"},
+		"@acme":                  {"scoped name @acme must be formatted as @scope/name"},
+		"@/my-package":           {"scoped name @/my-package must be formatted as @scope/name"},
+		"@ac me/my-package":      {"scope can only contain url-safe characters"},
+		"@Acme/My:Package":       {"name can't contain capital letters", "name can only contain url-safe characters"},
+		strings.Repeat("a", 215): {"name can't be longer than 214 characters"},
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, nameProblems(name), name)
+	}
+}
+
+func TestPackageProblems(t *testing.T) {
+	npm := &npmPackage{Name: "my-package", Version: "1.0.0"}
+	assert.Empty(t, packageProblems(npm))
+
+	npm = &npmPackage{Name: "My-Package", Version: "v1.0", Private: true}
+	assert.Equal(t, []string{
+		"name can't contain capital letters",
+		`version "v1.0" is not a valid semantic version`,
+		"package is private",
+	}, packageProblems(npm))
+}
+
+func TestTargetProblems(t *testing.T) {
+	folder := t.TempDir()
+	for _, name := range []string{"lib/index.js", "lib/index.mjs", "bin/cli.js", "types/index.d.ts"} {
+		file := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	valid := `{
+		"main": "./lib",
+		"module": "lib/index.mjs",
+		"types": "types/index.d.ts",
+		"bin": {"cli": "./bin/cli.js"},
+		"exports": {
+			".": {"import": "./lib/index.mjs", "require": ["./lib/index.js"]},
+			"./features/*": "./lib/features/*.js",
+			"./internal": null
+		}
+	}`
+	assert.Empty(t, targetProblems(folder, []byte(valid)))
+
+	invalid := `{
+		"main": "dist/index",
+		"module": "lib",
+		"typings": "index.d.ts",
+		"bin": "./bin/missing.js",
+		"exports": {".": {"import": "./dist/index.mjs", "default": "./lib/index.js"}}
+	}`
+	assert.Equal(t, []string{
+		"main dist/index does not exist",
+		"module lib does not exist",
+		"typings index.d.ts does not exist",
+		"bin ./bin/missing.js does not exist",
+		"export ./dist/index.mjs does not exist",
+	}, targetProblems(folder, []byte(invalid)))
+
+	assert.Equal(t, []string{"bin must be a file or a map of commands to files"},
+		targetProblems(folder, []byte(`{"bin": ["cli.js"]}`)))
+}
+
+func TestReadPackageFileProblems(t *testing.T) {
+	folder := t.TempDir()
+	contents := `{"name": "My Package", "version": "1.0", "private": true, "main": "index.js"}`
+	if err := os.WriteFile(filepath.Join(folder, "package.json"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// every problem is reported at once
+	_, err := readPackageFile(folder, true)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "problems in "+filepath.Join(folder, "package.json")+":\n"+
+			"  name can't contain capital letters\n"+
+			"  name can only contain url-safe characters\n"+
+			"  version \"1.0\" is not a valid semantic version\n"+
+			"  package is private\n"+
+			"  main index.js does not exist", err.Error())
+	}
+
+	// managing published versions only needs the name and version
+	_, err = readPackageFile(folder, false)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "problems in "+filepath.Join(folder, "package.json")+":\n"+
+			"  name can't contain capital letters\n"+
+			"  name can only contain url-safe characters\n"+
+			"  version \"1.0\" is not a valid semantic version", err.Error())
+	}
+
+	contents = `{"name": "my-package", "version": "1.0.0", "private": true, "main": "index.js"}`
+	if err := os.WriteFile(filepath.Join(folder, "package.json"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = readPackageFile(folder, false)
+	assert.Nil(t, err)
+}
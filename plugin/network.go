@@ -0,0 +1,308 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pemHeader starts the PEM encoded contents of a certificate, telling them
+// apart from a path.
+const pemHeader = "-----BEGIN"
+
+// configureNetwork reads the certificate and proxy settings and applies them
+// to the HTTP client of the network, so requests made by the plugin go through
+// the same proxy and use the same certificates as the client publishing.
+func (p *Plugin) configureNetwork() error {
+	if (p.settings.ClientCert == "") != (p.settings.ClientKey == "") {
+		return fmt.Errorf("client cert and client key must be given together")
+	}
+
+	if p.settings.CACert == "" && p.settings.ClientCert == "" &&
+		p.settings.Proxy == "" && p.settings.HTTPSProxy == "" && p.settings.NoProxy == "" {
+		return nil
+	}
+
+	var transport *http.Transport
+	if p.network.Client != nil {
+		if t, ok := p.network.Client.Transport.(*http.Transport); ok {
+			transport = t.Clone()
+		}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{} //nolint:gosec
+	} else {
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+
+	if p.settings.CACert != "" {
+		caCert, err := readPEM(p.settings.CACert, "ca cert")
+		if err != nil {
+			return err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("no certificates found in the ca cert")
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+		p.settings.caCert = caCert
+	}
+
+	if p.settings.ClientCert != "" {
+		clientCert, err := readPEM(p.settings.ClientCert, "client cert")
+		if err != nil {
+			return err
+		}
+		clientKey, err := readPEM(p.settings.ClientKey, "client key")
+		if err != nil {
+			return err
+		}
+
+		certificate, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return fmt.Errorf("invalid client cert: %w", err)
+		}
+
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+		p.settings.clientCert = clientCert
+		p.settings.clientKey = clientKey
+	}
+
+	httpProxy, err := parseProxy(p.settings.Proxy)
+	if err != nil {
+		return err
+	}
+	httpsProxy, err := parseProxy(p.settings.HTTPSProxy)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = proxyFunc(transport.Proxy, httpProxy, httpsProxy, p.settings.NoProxy)
+
+	client := &http.Client{Transport: transport}
+	if p.network.Client != nil {
+		client.Timeout = p.network.Client.Timeout
+		client.Jar = p.network.Client.Jar
+		client.CheckRedirect = p.network.Client.CheckRedirect
+	}
+	p.network.Client = client
+
+	logrus.WithFields(logrus.Fields{
+		"ca_cert":     p.settings.CACert != "",
+		"client_cert": p.settings.ClientCert != "",
+		"proxy":       p.settings.Proxy != "",
+		"https_proxy": p.settings.HTTPSProxy != "",
+		"noproxy":     p.settings.NoProxy,
+	}).Info("Configured network")
+
+	return nil
+}
+
+// writeCertificates writes the certificates to the temporary directory so
+// the client can read them. The ca cert is referenced directly when given as
+// a path, while the client cert and key are always written as private files.
+func (p *Plugin) writeCertificates() error {
+	if p.settings.CACert != "" {
+		if isPEM(p.settings.CACert) {
+			caFile, err := p.writeTempFile("ca.pem", p.settings.caCert)
+			if err != nil {
+				return fmt.Errorf("could not write ca cert: %w", err)
+			}
+			p.settings.caFile = caFile
+		} else {
+			p.settings.caFile = p.settings.CACert
+		}
+	}
+
+	if p.settings.ClientCert != "" {
+		certFile, err := p.writeTempFile("client.crt", p.settings.clientCert)
+		if err != nil {
+			return fmt.Errorf("could not write client cert: %w", err)
+		}
+		keyFile, err := p.writeTempFile("client.key", p.settings.clientKey)
+		if err != nil {
+			return fmt.Errorf("could not write client key: %w", err)
+		}
+
+		p.settings.certFile = certFile
+		p.settings.keyFile = keyFile
+	}
+
+	return nil
+}
+
+// writeTempFile writes a private file to the temporary directory.
+func (p *Plugin) writeTempFile(name string, contents []byte) (string, error) {
+	dir, err := p.tempDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, contents, 0600); err != nil { //nolint:gomnd
+		return "", err
+	}
+
+	return path, nil
+}
+
+// isPEM determines whether the value holds PEM encoded contents rather than
+// a path.
+func isPEM(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), pemHeader)
+}
+
+// readPEM reads the PEM encoded value, which is either given directly or as
+// the path of a file.
+func readPEM(value, name string) ([]byte, error) {
+	if isPEM(value) {
+		return []byte(value), nil
+	}
+
+	contents, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s at %s: %w", name, value, err)
+	}
+
+	return contents, nil
+}
+
+// parseProxy parses the url of a proxy, returning nil when none is given.
+func parseProxy(value string) (*url.URL, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	proxy, err := url.Parse(value)
+	if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+		// the value is left out as it may hold credentials
+		return nil, fmt.Errorf("invalid proxy, expected an url like http://proxy.acme.com:8080")
+	}
+
+	return proxy, nil
+}
+
+// proxyFunc picks the proxy for a request the same way npm does. The https
+// proxy falls back to the http proxy and hosts matching noproxy are requested
+// directly. Requests fall back to the given proxy func when no proxy is
+// configured.
+func proxyFunc(fallback func(*http.Request) (*url.URL, error), httpProxy, httpsProxy *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	if httpsProxy == nil {
+		httpsProxy = httpProxy
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if matchesNoProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+
+		switch {
+		case req.URL.Scheme == "https" && httpsProxy != nil:
+			return httpsProxy, nil
+		case req.URL.Scheme == "http" && httpProxy != nil:
+			return httpProxy, nil
+		case fallback != nil:
+			return fallback(req)
+		}
+
+		return nil, nil
+	}
+}
+
+// matchesNoProxy determines whether the host is excluded from proxying by
+// the comma separated noproxy list. An entry matches the host and its
+// subdomains, while * matches every host.
+func matchesNoProxy(host, noProxy string) bool {
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+
+		host = strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// caEnv trusts the CA certificate in addition to the system certificates.
+// The cafile of the npmrc and the caFilePath of yarn replace them instead.
+func caEnv(settings *Settings) []string {
+	if settings.caFile == "" {
+		return nil
+	}
+
+	return []string{"NODE_EXTRA_CA_CERTS=" + settings.caFile}
+}
+
+// npmrcContentsNetwork creates the certificate and proxy lines of the npmrc.
+// The client cert is presented to the registry and the registries of the
+// scopes.
+func npmrcContentsNetwork(settings *Settings) (string, error) {
+	var b strings.Builder
+
+	if settings.certFile != "" {
+		registries := []string{settings.Registry}
+		for _, scope := range sortedScopes(settings.scopes) {
+			registries = append(registries, settings.scopes[scope].Registry)
+		}
+
+		written := map[string]bool{}
+		for _, registry := range registries {
+			nerfDart, err := registryNerfDart(registry)
+			if err != nil {
+				return "", err
+			}
+			if written[nerfDart] {
+				continue
+			}
+			written[nerfDart] = true
+
+			fmt.Fprintf(&b, "\n%s:certfile=%s", nerfDart, settings.certFile)
+			fmt.Fprintf(&b, "\n%s:keyfile=%s", nerfDart, settings.keyFile)
+		}
+	}
+	if settings.Proxy != "" {
+		fmt.Fprintf(&b, "\nproxy=%s", settings.Proxy)
+	}
+	if settings.HTTPSProxy != "" {
+		fmt.Fprintf(&b, "\nhttps-proxy=%s", settings.HTTPSProxy)
+	}
+	if settings.NoProxy != "" {
+		fmt.Fprintf(&b, "\nnoproxy=%s", settings.NoProxy)
+	}
+
+	return b.String(), nil
+}
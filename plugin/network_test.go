@@ -0,0 +1,245 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesNoProxy(t *testing.T) {
+	noProxy := "localhost, .internal.acme.com,npm.acme.com:8443"
+
+	assert.True(t, matchesNoProxy("localhost", noProxy))
+	assert.True(t, matchesNoProxy("npm.internal.acme.com", noProxy))
+	assert.True(t, matchesNoProxy("NPM.acme.com", noProxy))
+	assert.False(t, matchesNoProxy("acme.com", noProxy))
+	assert.False(t, matchesNoProxy("registry.npmjs.org", noProxy))
+	assert.False(t, matchesNoProxy("registry.npmjs.org", ""))
+	assert.True(t, matchesNoProxy("registry.npmjs.org", "*"))
+}
+
+func TestProxyFunc(t *testing.T) {
+	httpProxy, _ := parseProxy("http://proxy.acme.com:8080")
+	httpsProxy, _ := parseProxy("http://secure-proxy.acme.com:8080")
+
+	proxyFor := func(proxy func(*http.Request) (*url.URL, error), target string) string {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		u, _ := proxy(req)
+		if u == nil {
+			return ""
+		}
+		return u.Host
+	}
+
+	proxy := proxyFunc(nil, httpProxy, httpsProxy, "npm.acme.com")
+	assert.Equal(t, "proxy.acme.com:8080", proxyFor(proxy, "http://registry.npmjs.org/"))
+	assert.Equal(t, "secure-proxy.acme.com:8080", proxyFor(proxy, "https://registry.npmjs.org/"))
+	assert.Equal(t, "", proxyFor(proxy, "https://npm.acme.com/"))
+
+	// the https proxy falls back to the proxy
+	proxy = proxyFunc(nil, httpProxy, nil, "")
+	assert.Equal(t, "proxy.acme.com:8080", proxyFor(proxy, "https://registry.npmjs.org/"))
+
+	_, err := parseProxy("proxy.acme.com")
+	assert.NotNil(t, err)
+}
+
+func TestConfigureNetwork(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "my-awesome-package", "versions": {}}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	p := initPlugin()
+	p.network.Client = nil
+	p.settings.CACert = caCert
+	p.settings.Registry = server.URL
+
+	if assert.Nil(t, p.configureNetwork()) {
+		client, _ := p.registryClient()
+		_, err := client.Packument(p.context(), "my-awesome-package")
+		assert.Nil(t, err)
+	}
+
+	// the certificate is read from a path
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, []byte(caCert), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p = initPlugin()
+	p.network.Client = nil
+	p.settings.CACert = caPath
+	p.settings.Registry = server.URL
+
+	if assert.Nil(t, p.configureNetwork()) {
+		client, _ := p.registryClient()
+		_, err := client.Packument(p.context(), "my-awesome-package")
+		assert.Nil(t, err)
+	}
+
+	p.settings.CACert = "-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----"
+	assert.NotNil(t, p.configureNetwork())
+
+	p.settings.CACert = filepath.Join(t.TempDir(), "missing.pem")
+	assert.NotNil(t, p.configureNetwork())
+}
+
+func TestNetworkRCContents(t *testing.T) {
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.CACert = "-----BEGIN CERTIFICATE-----"
+	p.settings.caCert = []byte(p.settings.CACert)
+	p.settings.Proxy = "http://proxy.acme.com:8080"
+	p.settings.NoProxy = "localhost"
+
+	if assert.Nil(t, p.writeNpmrc()) {
+		defer p.cleanup()
+
+		contents, _ := os.ReadFile(p.npmrc)
+		assert.Equal(t, "//fakenpm.reg.org/good/path/:_authToken=token"+
+			"\nproxy=http://proxy.acme.com:8080"+
+			"\nnoproxy=localhost"+
+			"\nregistry=https://fakenpm.reg.org/good/path"+
+			"\nstrict-ssl=false", string(contents))
+
+		caCert, _ := os.ReadFile(filepath.Join(p.temp, "ca.pem"))
+		assert.Equal(t, p.settings.caCert, caCert)
+
+		// the ca is trusted in addition to the system certificates
+		env := npmManager{}.configEnv(&p.settings, p.npmrc, false)
+		assert.Contains(t, env, "NODE_EXTRA_CA_CERTS="+filepath.Join(p.temp, "ca.pem"))
+	}
+
+	p.settings.HTTPSProxy = "http://secure-proxy.acme.com:8080"
+	p.settings.caFile = "/etc/ssl/acme.pem"
+	env := yarnManager{}.configEnv(&p.settings, p.npmrc, false)
+	assert.Contains(t, env, "NODE_EXTRA_CA_CERTS=/etc/ssl/acme.pem")
+	assert.Contains(t, env, "YARN_HTTP_PROXY=http://proxy.acme.com:8080")
+	assert.Contains(t, env, "YARN_HTTPS_PROXY=http://secure-proxy.acme.com:8080")
+}
+
+// generateClientCert creates a self-signed client certificate and its key.
+func generateClientCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "drone"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestConfigureNetworkClientCert(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "my-awesome-package", "versions": {}}`)) //nolint:errcheck
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	clientCert, clientKey := generateClientCert(t)
+
+	p := initPlugin()
+	p.network.Client = server.Client()
+	p.settings.Registry = server.URL
+	p.settings.ClientCert = clientCert
+	assert.NotNil(t, p.configureNetwork())
+
+	// without the client cert the registry refuses the connection
+	client, _ := p.registryClient()
+	_, err := client.Packument(p.context(), "my-awesome-package")
+	assert.NotNil(t, err)
+
+	p.settings.ClientKey = clientKey
+	if assert.Nil(t, p.configureNetwork()) {
+		client, _ := p.registryClient()
+		_, err := client.Packument(p.context(), "my-awesome-package")
+		assert.Nil(t, err)
+	}
+
+	p.settings.ClientKey = p.settings.ClientCert
+	assert.NotNil(t, p.configureNetwork())
+}
+
+func TestClientCertRCContents(t *testing.T) {
+	clientCert, clientKey := generateClientCert(t)
+	keyPath := filepath.Join(t.TempDir(), "client.key")
+	if err := os.WriteFile(keyPath, []byte(clientKey), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.ClientCert = clientCert
+	p.settings.ClientKey = keyPath
+	p.settings.Scopes = `{"@acme": {"registry": "https://npm.acme.com/"}, "@same": {"registry": "https://fakenpm.reg.org/good/path"}}`
+
+	if assert.Nil(t, p.parseScopes()) && assert.Nil(t, p.configureNetwork()) && assert.Nil(t, p.writeNpmrc()) {
+		certFile := filepath.Join(p.temp, "client.crt")
+		keyFile := filepath.Join(p.temp, "client.key")
+
+		contents, _ := os.ReadFile(p.npmrc)
+		// the cert is presented to each registry once
+		assert.Equal(t, "//fakenpm.reg.org/good/path/:_authToken=token"+
+			"\n@acme:registry=https://npm.acme.com/"+
+			"\n@same:registry=https://fakenpm.reg.org/good/path"+
+			"\n//fakenpm.reg.org/good/path/:certfile="+certFile+
+			"\n//fakenpm.reg.org/good/path/:keyfile="+keyFile+
+			"\n//npm.acme.com/:certfile="+certFile+
+			"\n//npm.acme.com/:keyfile="+keyFile+
+			"\nregistry=https://fakenpm.reg.org/good/path"+
+			"\nstrict-ssl=false", string(contents))
+
+		for _, path := range []string{certFile, keyFile} {
+			info, err := os.Stat(path)
+			if assert.Nil(t, err) {
+				assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+			}
+		}
+		key, _ := os.ReadFile(keyFile)
+		assert.Equal(t, clientKey, string(key))
+
+		p.cleanup()
+		_, err := os.Stat(keyFile)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
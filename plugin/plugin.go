@@ -6,6 +6,8 @@
 package plugin
 
 import (
+	"time"
+
 	"github.com/drone-plugins/drone-plugin-lib/drone"
 )
 
@@ -14,15 +16,31 @@ type Plugin struct {
 	settings Settings
 	pipeline drone.Pipeline
 	network  drone.Network
+	runner   Runner
+
+	npmrc string
+	temp  string
+
+	// sleep waits between retries, replaced in tests
+	sleep func(time.Duration)
 }
 
 // New initializes a plugin from the given Settings, Pipeline, and Network.
 //
 //nolint:gocritic
 func New(settings Settings, pipeline drone.Pipeline, network drone.Network) drone.Plugin {
+	return NewWithRunner(settings, pipeline, network, execRunner{})
+}
+
+// NewWithRunner initializes a plugin which runs its commands through the
+// given Runner.
+//
+//nolint:gocritic
+func NewWithRunner(settings Settings, pipeline drone.Pipeline, network drone.Network, runner Runner) drone.Plugin {
 	return &Plugin{
 		settings: settings,
 		pipeline: pipeline,
 		network:  network,
+		runner:   runner,
 	}
 }
@@ -6,6 +6,7 @@
 package plugin
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -14,37 +15,54 @@ func TestTokenRCContents(t *testing.T) {
 		Registry: "https://npm.someorg.com/",
 		Token:    "token",
 	}
-	actual := npmrcContentsToken(&settings)
+	actual, _ := npmrcContentsToken(&settings)
 	expected := "//npm.someorg.com/:_authToken=token"
 	if actual != expected {
 		t.Errorf("Unexpected token settings (Got: %s, Expected: %s)", actual, expected)
 	}
 
 	settings.Registry = "https://npm.someorg.com/with/path/"
-	actual = npmrcContentsToken(&settings)
+	actual, _ = npmrcContentsToken(&settings)
 	expected = "//npm.someorg.com/with/path/:_authToken=token"
 	if actual != expected {
 		t.Errorf("Unexpected token settings (Got: %s, Expected: %s)", actual, expected)
 	}
 
 	settings.Registry = globalRegistry
-	actual = npmrcContentsToken(&settings)
+	actual, _ = npmrcContentsToken(&settings)
 	expected = "//registry.npmjs.org/:_authToken=token"
 	if actual != expected {
 		t.Errorf("Unexpected token settings (Got: %s, Expected: %s)", actual, expected)
 	}
 
 	settings.Registry = "https://npm.someorg.com"
-	actual = npmrcContentsToken(&settings)
+	actual, _ = npmrcContentsToken(&settings)
 	expected = "//npm.someorg.com/:_authToken=token"
 	if actual != expected {
 		t.Errorf("Unexpected token settings (Got: %s, Expected: %s)", actual, expected)
 	}
 
 	settings.Registry = "https://npm.someorg.com/with/path"
-	actual = npmrcContentsToken(&settings)
+	actual, _ = npmrcContentsToken(&settings)
 	expected = "//npm.someorg.com/with/path/:_authToken=token"
 	if actual != expected {
 		t.Errorf("Unexpected token settings (Got: %s, Expected: %s)", actual, expected)
 	}
 }
+
+func TestPublishCommand(t *testing.T) {
+	settings := Settings{}
+	actual := strings.Join(npmManager{}.publishCommand(&settings, "").Args, " ")
+	expected := "npm publish"
+	if actual != expected {
+		t.Errorf("Unexpected publish command (Got: %s, Expected: %s)", actual, expected)
+	}
+
+	settings.Access = "public"
+	settings.DryRun = true
+	actual = strings.Join(npmManager{}.publishCommand(&settings, "next").Args, " ")
+	expected = "npm publish --tag next --access public --dry-run"
+	if actual != expected {
+		t.Errorf("Unexpected publish command (Got: %s, Expected: %s)", actual, expected)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/sirupsen/logrus"
+)
+
+// contentsPolicy restricts the files included in a published package.
+type contentsPolicy struct {
+	maxUnpackedSize int64
+	maxFiles        int
+	forbidden       []string
+	required        []string
+	entryPoints     bool
+}
+
+// sizeRegexp matches a size with an optional unit.
+var sizeRegexp = regexp.MustCompile(`^(\d+)\s*([a-zA-Z]*)$`)
+
+// sizeUnits are the multipliers of the units a size can be given in.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// parsePolicy reads the contents policy from the settings, leaving it unset
+// when no rule is configured.
+func (p *Plugin) parsePolicy() error {
+	policy := &contentsPolicy{
+		maxFiles:    p.settings.MaxFiles,
+		forbidden:   splitList(p.settings.ForbiddenFiles),
+		required:    splitList(p.settings.RequiredFiles),
+		entryPoints: p.settings.RequireEntryPoints,
+	}
+
+	if p.settings.MaxUnpackedSize != "" {
+		size, err := parseSize(p.settings.MaxUnpackedSize)
+		if err != nil {
+			return err
+		}
+		policy.maxUnpackedSize = size
+	}
+	if policy.maxFiles < 0 {
+		return fmt.Errorf("invalid max files %d", policy.maxFiles)
+	}
+
+	if policy.maxUnpackedSize == 0 && policy.maxFiles == 0 && len(policy.forbidden) == 0 &&
+		len(policy.required) == 0 && !policy.entryPoints {
+		return nil
+	}
+
+	p.settings.policy = policy
+	return nil
+}
+
+// parseSize parses a number of bytes with an optional unit, such as 200MB or
+// 512KiB.
+func parseSize(value string) (int64, error) {
+	match := sizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %s", value)
+	}
+
+	unit, found := sizeUnits[strings.ToLower(match[2])]
+	if !found {
+		return 0, fmt.Errorf("invalid size %s, unsupported unit %s", value, match[2])
+	}
+
+	size, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %s: %w", value, err)
+	}
+
+	return size * unit, nil
+}
+
+// checkContents packs the package the way it is published, or reads the
+// prebuilt tarball, and verifies the included files against the policy.
+// Every violation is reported along with the contents of the package.
+func (p *Plugin) checkContents(npm *npmPackage) error {
+	tarball, err := p.contentsTarball(npm)
+	if err != nil {
+		return fmt.Errorf("could not pack package: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":     npm.Name,
+		"files":    len(tarball.Files),
+		"unpacked": tarball.UnpackedSize,
+	}).Info("Checking package contents")
+
+	violations, err := p.settings.policy.violations(tarball)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	printTarball(tarball)
+	return fmt.Errorf("package contents violate the policy:\n  %s", strings.Join(violations, "\n  "))
+}
+
+// contentsTarball creates the tarball whose contents are checked. The client
+// packs the package unless publishing over HTTP, as it selects the files of
+// the package itself.
+func (p *Plugin) contentsTarball(npm *npmPackage) (*pack.Tarball, error) {
+	if p.settings.HTTPPublish || p.settings.tarball != "" {
+		return p.packPackage(npm)
+	}
+
+	dir, err := os.MkdirTemp("", "drone-npm-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tarball, _, err := p.packWithClient(npm, dir)
+	return tarball, err
+}
+
+// violations lists the rules of the policy the tarball breaks.
+func (c *contentsPolicy) violations(tarball *pack.Tarball) ([]string, error) {
+	var violations []string
+
+	if c.maxUnpackedSize > 0 && tarball.UnpackedSize > c.maxUnpackedSize {
+		violations = append(violations, fmt.Sprintf("unpacked size %d exceeds the maximum of %d", tarball.UnpackedSize, c.maxUnpackedSize))
+	}
+	if c.maxFiles > 0 && len(tarball.Files) > c.maxFiles {
+		violations = append(violations, fmt.Sprintf("%d files exceed the maximum of %d", len(tarball.Files), c.maxFiles))
+	}
+
+	for _, file := range tarball.Files {
+		for _, pattern := range c.forbidden {
+			if matchesFile(pattern, file.Path) {
+				violations = append(violations, fmt.Sprintf("%s is forbidden by %s", file.Path, pattern))
+				break
+			}
+		}
+	}
+
+	for _, pattern := range c.required {
+		if !includesFile(tarball, pattern) {
+			violations = append(violations, fmt.Sprintf("no file matches required %s", pattern))
+		}
+	}
+
+	if c.entryPoints {
+		missing, err := missingEntryPoints(tarball)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, missing...)
+	}
+
+	return violations, nil
+}
+
+// matchesFile determines whether the path matches the pattern. Patterns
+// without a slash match the name of a file at any depth, as in an ignore
+// file.
+func matchesFile(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		return pack.Match(pattern, path.Base(file))
+	}
+
+	return pack.Match(strings.TrimPrefix(pattern, "/"), file)
+}
+
+// includesFile determines whether a file of the tarball matches the required
+// pattern, ignoring case. A pattern without an extension also matches files
+// with one, so README matches README.md.
+func includesFile(tarball *pack.Tarball, pattern string) bool {
+	pattern = strings.ToLower(strings.TrimPrefix(pattern, "/"))
+
+	for _, file := range tarball.Files {
+		name := strings.ToLower(file.Path)
+		if pack.Match(pattern, name) {
+			return true
+		}
+		if path.Ext(pattern) == "" && pack.Match(pattern+".*", name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// missingEntryPoints lists the entry points declared in the package.json of
+// the tarball which it does not include. The main entry point is resolved
+// like node does, allowing the extension or index file to be left out.
+func missingEntryPoints(tarball *pack.Tarball) ([]string, error) {
+	manifest := struct {
+		Main    string `json:"main"`
+		Types   string `json:"types"`
+		Typings string `json:"typings"`
+	}{}
+	if err := json.Unmarshal(tarball.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse package.json: %w", err)
+	}
+
+	files := map[string]bool{}
+	for _, file := range tarball.Files {
+		files[file.Path] = true
+	}
+	included := func(entry string) bool {
+		return files[entry]
+	}
+
+	var missing []string
+	if pack.CleanPath(manifest.Main) != "" && !mainExists(manifest.Main, included) {
+		missing = append(missing, fmt.Sprintf("main entry point %s is not included", manifest.Main))
+	}
+	if entry := pack.CleanPath(manifest.Types); entry != "" && !files[entry] {
+		missing = append(missing, fmt.Sprintf("types entry point %s is not included", manifest.Types))
+	}
+	if entry := pack.CleanPath(manifest.Typings); entry != "" && !files[entry] {
+		missing = append(missing, fmt.Sprintf("typings entry point %s is not included", manifest.Typings))
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024":   1024,
+		"200MB":  200 * 1000 * 1000,
+		"10 kb":  10 * 1000,
+		"512KiB": 512 * 1024,
+		"1GiB":   1 << 30,
+	}
+	for value, expected := range tests {
+		size, err := parseSize(value)
+		if assert.Nil(t, err, value) {
+			assert.Equal(t, expected, size, value)
+		}
+	}
+
+	for _, value := range []string{"", "MB", "-1", "10 parsecs"} {
+		_, err := parseSize(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p := initPlugin()
+	if assert.Nil(t, p.parsePolicy()) {
+		assert.Nil(t, p.settings.policy)
+	}
+
+	p.settings.MaxUnpackedSize = "2kb"
+	p.settings.ForbiddenFiles = "**/.env, *.pem"
+	if assert.Nil(t, p.parsePolicy()) && assert.NotNil(t, p.settings.policy) {
+		assert.Equal(t, int64(2000), p.settings.policy.maxUnpackedSize)
+		assert.Equal(t, []string{"**/.env", "*.pem"}, p.settings.policy.forbidden)
+	}
+
+	p.settings.MaxUnpackedSize = "lots"
+	assert.NotNil(t, p.parsePolicy())
+}
+
+func TestPolicyViolations(t *testing.T) {
+	tarball := &pack.Tarball{
+		Manifest: []byte(`{"name": "my-package", "main": "lib/index", "types": "lib/index.d.ts"}`),
+		Files: []pack.File{
+			{Path: "README.md", Size: 10},
+			{Path: "lib/index.js", Size: 10},
+			{Path: "config/.env", Size: 10},
+			{Path: "certs/server.pem", Size: 10},
+			{Path: "package.json", Size: 10},
+		},
+		UnpackedSize: 50,
+	}
+
+	policy := &contentsPolicy{
+		forbidden:   []string{"**/.env", "*.pem"},
+		required:    []string{"README", "LICENSE"},
+		entryPoints: true,
+	}
+	violations, err := policy.violations(tarball)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{
+			"config/.env is forbidden by **/.env",
+			"certs/server.pem is forbidden by *.pem",
+			"no file matches required LICENSE",
+			"types entry point lib/index.d.ts is not included",
+		}, violations)
+	}
+
+	policy = &contentsPolicy{maxUnpackedSize: 40, maxFiles: 4}
+	violations, err = policy.violations(tarball)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{
+			"unpacked size 50 exceeds the maximum of 40",
+			"5 files exceed the maximum of 4",
+		}, violations)
+	}
+
+	policy = &contentsPolicy{maxUnpackedSize: 50, maxFiles: 5, required: []string{"readme.*", "lib/*.js"}}
+	violations, err = policy.violations(tarball)
+	if assert.Nil(t, err) {
+		assert.Empty(t, violations)
+	}
+}
+
+func TestValidateContentsPolicy(t *testing.T) {
+	folder := t.TempDir()
+	files := map[string]string{
+		"package.json": `{"name": "my-awesome-package", "version": "1.0.0"}`,
+		"index.js":     "index",
+		".env":         "SECRET=1",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(folder, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := &fakeRunner{}
+	runner.packs(t)
+	p := initPlugin()
+	p.runner = runner
+	p.settings.Token = "token"
+	p.settings.SkipRegistryValidation = true
+	p.settings.Folder = folder
+	p.settings.ForbiddenFiles = "**/.env"
+
+	// the client packs the files it publishes
+	assert.NotNil(t, p.Validate())
+	if assert.Len(t, runner.commands, 1) {
+		assert.True(t, strings.HasPrefix(runner.commands[0], "npm pack --pack-destination "))
+	}
+
+	// the plugin packs the files it uploads
+	runner.commands = nil
+	p.settings.HTTPPublish = true
+	assert.NotNil(t, p.Validate())
+	assert.Empty(t, runner.commands)
+	p.settings.HTTPPublish = false
+
+	// the policy only applies when publishing
+	p.settings.Action = "dist-tag"
+	p.settings.AddDistTags = "stable"
+	assert.Nil(t, p.Validate())
+
+	p.settings.Action = "publish"
+	if err := os.Remove(filepath.Join(folder, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, p.Validate())
+}
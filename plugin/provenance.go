@@ -0,0 +1,55 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// errProvenance rejects provenance attestations, which npm only generates on
+// GitHub Actions and GitLab CI.
+var errProvenance = errors.New("provenance is only supported by npm on GitHub Actions and GitLab CI, not on drone")
+
+// exchangeOIDCToken exchanges the oidc token for a short-lived token allowed
+// to publish the package and rewrites the credential file to use it. Nothing
+// is done when publishing with static credentials.
+func (p *Plugin) exchangeOIDCToken(npm *npmPackage) error {
+	if p.settings.OIDCToken == "" {
+		return nil
+	}
+
+	client, err := registry.New(p.settings.OIDCExchangeURL, registry.Auth{
+		Token: p.settings.OIDCToken,
+	}, p.network.Client)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":     npm.Name,
+		"registry": p.settings.OIDCExchangeURL,
+	}).Info("Exchanging oidc token")
+
+	token, err := client.ExchangeOIDCToken(p.context(), npm.Name)
+	if err != nil {
+		return err
+	}
+	p.settings.Token = token
+
+	if p.settings.HTTPPublish {
+		return nil
+	}
+
+	if err := p.writeNpmrc(); err != nil {
+		return fmt.Errorf("could not create npmrc: %w", err)
+	}
+
+	return nil
+}
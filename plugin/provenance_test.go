@@ -0,0 +1,103 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOIDC(t *testing.T) {
+	p := initPlugin()
+	p.settings.SkipRegistryValidation = true
+	p.settings.Username = ""
+	p.settings.Password = ""
+	p.settings.OIDCToken = "id-token"
+	p.settings.Token = "token"
+	assert.NotNil(t, p.Validate())
+
+	p.settings.Token = ""
+	if assert.Nil(t, p.Validate()) {
+		assert.Equal(t, p.settings.Registry, p.settings.OIDCExchangeURL)
+	}
+}
+
+func TestExecuteWithOIDC(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddTrustedPublisher("id-token", "my-awesome-package")
+
+	// the exchange is served by a stand-in for the registry
+	exchange := registrytest.NewServer()
+	defer exchange.Close()
+	exchange.AddTrustedPublisher("id-token", "my-awesome-package")
+
+	runner := &fakeRunner{}
+	p := initPlugin()
+	p.settings.Username = ""
+	p.settings.Password = ""
+	p.settings.OIDCToken = "id-token"
+	p.settings.OIDCExchangeURL = exchange.URL
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.network.Client = server.Client()
+	p.runner = runner
+
+	var contents []byte
+	runner.inspect = func(cmd *exec.Cmd) {
+		contents, _ = os.ReadFile(p.npmrc)
+	}
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		assert.NotContains(t, runner.commands, "npm whoami")
+		assert.Equal(t, "npm publish", runner.commands[len(runner.commands)-1])
+		// the rewritten npmrc keeps the registry settings
+		assert.Equal(t, "//"+strings.TrimPrefix(server.URL, "http://")+"/:_authToken=npm_oidc_1"+
+			"\nregistry="+server.URL+
+			"\nstrict-ssl=false", string(contents))
+		assert.Equal(t, []string{"POST /-/npm/v1/oidc/token/exchange/package/my-awesome-package"}, exchange.Requests())
+	}
+
+	// publishing over HTTP with the exchanged token
+	p = initPlugin()
+	p.settings.Username = ""
+	p.settings.Password = ""
+	p.settings.OIDCToken = "id-token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		_, ok := server.Packument("my-awesome-package")
+		assert.True(t, ok)
+	}
+
+	// an untrusted id token is not exchanged
+	p = initPlugin()
+	p.settings.Username = ""
+	p.settings.Password = ""
+	p.settings.OIDCToken = "other-token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.Snapshot = true
+	p.pipeline.Build.Number = 2
+	p.pipeline.Commit.SHA = "5f0c9e1"
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) {
+		err := p.Execute()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "could not exchange oidc token")
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// publicAccess publishes a scoped package publicly.
+	publicAccess = "public"
+
+	// restrictedAccess publishes a scoped package privately.
+	restrictedAccess = "restricted"
+)
+
+// ComparePublishConfig verifies the tag and access of the publishConfig in
+// package.json agree with the drone settings. Settings take precedence and the
+// publishConfig fills in what they leave unset, so only values set in both
+// which differ conflict. Provenance can't be requested by the publishConfig.
+func (p *Plugin) ComparePublishConfig(nc npmConfig) error {
+	if nc.Tag != "" {
+		tag := p.settings.Tag
+		if p.settings.Snapshot {
+			tag = p.settings.SnapshotTag
+		}
+		if tag != "" && tag != nc.Tag {
+			return fmt.Errorf("tag values do not match .drone.yml: %s package.json: %s", tag, nc.Tag)
+		}
+	}
+
+	switch nc.Access {
+	case "":
+	case publicAccess, restrictedAccess:
+		if p.settings.Access != "" && p.settings.Access != nc.Access {
+			return fmt.Errorf("access values do not match .drone.yml: %s package.json: %s", p.settings.Access, nc.Access)
+		}
+	default:
+		return fmt.Errorf("invalid package.json access %s, expected %s or %s", nc.Access, publicAccess, restrictedAccess)
+	}
+
+	if nc.Provenance {
+		return fmt.Errorf("package.json requests provenance: %w", errProvenance)
+	}
+
+	return nil
+}
+
+// applyPublishConfig determines the access the package is published with and
+// logs the effective publish configuration.
+func (p *Plugin) applyPublishConfig(npm *npmPackage) {
+	npm.access = p.settings.Access
+	if npm.access == "" {
+		npm.access = npm.Config.Access
+	}
+
+	tag := npm.tag
+	if tag == "" {
+		tag = latestTag
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":     npm.Name,
+		"registry": p.settings.Registry,
+		"tag":      tag,
+		"access":   npm.access,
+	}).Info("Effective publish configuration")
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePublishConfig writes a package with the publishConfig to a folder.
+func writePublishConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	folder := t.TempDir()
+	contents := `{"name": "my-awesome-package", "version": "1.0.0", "publishConfig": ` + config + `}`
+	if err := os.WriteFile(filepath.Join(folder, "package.json"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return folder
+}
+
+func TestComparePublishConfig(t *testing.T) {
+	p := initPlugin()
+	assert.Nil(t, p.ComparePublishConfig(npmConfig{Tag: "next", Access: "public"}))
+
+	p.settings.Tag = "next"
+	p.settings.Access = "public"
+	assert.Nil(t, p.ComparePublishConfig(npmConfig{Tag: "next", Access: "public"}))
+
+	p.settings.Tag = "beta"
+	assert.NotNil(t, p.ComparePublishConfig(npmConfig{Tag: "next"}))
+
+	// snapshots always use the snapshot tag
+	p.settings.Tag = ""
+	p.settings.Snapshot = true
+	p.settings.SnapshotTag = "canary"
+	assert.NotNil(t, p.ComparePublishConfig(npmConfig{Tag: "next"}))
+
+	p.settings.Snapshot = false
+	assert.NotNil(t, p.ComparePublishConfig(npmConfig{Access: "restricted"}))
+	assert.NotNil(t, p.ComparePublishConfig(npmConfig{Access: "everyone"}))
+
+	// npm can't attest provenance on drone
+	assert.NotNil(t, p.ComparePublishConfig(npmConfig{Provenance: true}))
+}
+
+func TestValidatePublishConfig(t *testing.T) {
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.SkipRegistryValidation = true
+	p.settings.Folder = writePublishConfig(t, `{"tag": "next", "access": "public"}`)
+
+	// the publishConfig fills in what the settings leave unset
+	if assert.Nil(t, p.Validate()) {
+		assert.Equal(t, "next", p.settings.npm.tag)
+		assert.Equal(t, "public", p.settings.npm.access)
+	}
+
+	p.settings.Access = "restricted"
+	assert.NotNil(t, p.Validate())
+}
+
+func TestValidatePublishConfigManagement(t *testing.T) {
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.SkipRegistryValidation = true
+	p.settings.Folder = writePublishConfig(t, `{"provenance": true}`)
+
+	// packages also published with provenance elsewhere can be deprecated
+	p.settings.Action = "deprecate"
+	p.settings.DeprecateRange = "<1.0.0"
+	p.settings.DeprecateMessage = "no longer supported"
+	assert.Nil(t, p.Validate())
+
+	// the publishConfig and version settings only matter when publishing
+	p.settings.Folder = writePublishConfig(t, `{"tag": "next", "provenance": true}`)
+	p.settings.Tag = "beta"
+	p.settings.Snapshot = true
+	assert.Nil(t, p.Validate())
+
+	p.settings.Action = "dist-tag"
+	p.settings.AddDistTags = "stable"
+	assert.Nil(t, p.Validate())
+
+	p.settings.Action = "unpublish"
+	assert.Nil(t, p.Validate())
+
+	p.settings.Action = "publish"
+	assert.NotNil(t, p.Validate())
+}
+
+func TestExecuteHTTPWithPublishConfigAccess(t *testing.T) {
+	var access string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body := struct {
+			Access string `json:"access"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		access = body.Access
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.SkipWhoami = true
+	p.settings.HTTPPublish = true
+	p.settings.Folder = writePublishConfig(t, `{"access": "restricted"}`)
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		assert.Equal(t, "restricted", access)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type (
+	// redactor masks credentials in text.
+	redactor struct {
+		secrets []string
+	}
+
+	// redactWriter masks credentials in the output written to the underlying
+	// writer. Output is passed on line by line so a credential split across
+	// two writes is still masked; Flush must be called once done.
+	redactWriter struct {
+		w        io.Writer
+		redactor *redactor
+		buf      []byte
+	}
+)
+
+const (
+	// redactedValue replaces masked credentials.
+	redactedValue = "********"
+
+	// maxBufferedOutput is the amount of output without a newline which is
+	// buffered before it is passed on.
+	maxBufferedOutput = 4096
+)
+
+// userinfoRegexp matches the userinfo of an url.
+var userinfoRegexp = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/\s@]+@`)
+
+// newRedactor creates a redactor for the given secrets. Empty secrets are
+// ignored and longer secrets are masked first so a secret containing another
+// one is masked completely.
+func newRedactor(secrets ...string) *redactor {
+	r := &redactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+
+	sort.SliceStable(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+
+	return r
+}
+
+// redactor creates a redactor for every credential in the settings, including
+// the base64 encoded forms written to the npmrc.
+func (p *Plugin) redactor() *redactor {
+	secrets := []string{p.settings.Token, p.settings.Password, p.settings.OIDCToken}
+	if p.settings.Password != "" {
+		secrets = append(secrets, basicAuth(p.settings.Username, p.settings.Password))
+	}
+
+	for _, scope := range p.settings.scopes {
+		secrets = append(secrets, scope.Token, scope.Password)
+		if scope.Password != "" {
+			secrets = append(secrets, basicAuth(scope.Username, scope.Password))
+		}
+	}
+
+	return newRedactor(secrets...)
+}
+
+// basicAuth returns the base64 encoded credentials used by the _auth setting.
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// Redact masks the credentials and url userinfo in the text.
+func (r *redactor) Redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+
+	return userinfoRegexp.ReplaceAllString(s, "${1}"+redactedValue+"@")
+}
+
+// longest returns the length of the longest secret.
+func (r *redactor) longest() int {
+	if len(r.secrets) == 0 {
+		return 0
+	}
+
+	return len(r.secrets[0])
+}
+
+// newRedactWriter creates a writer masking the credentials known to the
+// redactor before writing to w.
+func newRedactWriter(w io.Writer, r *redactor) *redactWriter {
+	return &redactWriter{
+		w:        w,
+		redactor: r,
+	}
+}
+
+// Write implements io.Writer.
+func (w *redactWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	if i := bytes.LastIndexByte(w.buf, '\n'); i >= 0 {
+		if err := w.write(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	}
+
+	// pass on long output without newlines, holding back enough to mask a
+	// credential continuing in the next write
+	if len(w.buf) > maxBufferedOutput {
+		redacted := w.redactor.Redact(string(w.buf))
+		keep := w.redactor.longest()
+		if keep > len(redacted) {
+			keep = len(redacted)
+		}
+
+		if _, err := io.WriteString(w.w, redacted[:len(redacted)-keep]); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], redacted[len(redacted)-keep:]...)
+	}
+
+	return len(p), nil
+}
+
+// Flush writes any buffered output.
+func (w *redactWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	err := w.write(w.buf)
+	w.buf = w.buf[:0]
+
+	return err
+}
+
+func (w *redactWriter) write(p []byte) error {
+	_, err := io.WriteString(w.w, w.redactor.Redact(string(p)))
+	return err
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRetryDelay is the delay before the first retry, doubled for each
+// following one.
+const defaultRetryDelay = 2 * time.Second
+
+// retry runs fn until it succeeds, fails permanently or the configured
+// retries are used up, backing off exponentially between attempts. The
+// before func is called ahead of every retry and stops retrying when it
+// reports the operation is already done.
+func (p *Plugin) retry(operation string, fn func() error, before func() (bool, error)) error {
+	delay := p.settings.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	err := fn()
+	for attempt := 1; err != nil && attempt <= p.settings.Retries && isTransient(err); attempt++ {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"delay":     delay,
+		}).Warn("Retrying after a failure")
+
+		p.wait(delay)
+		delay *= 2
+
+		if before != nil {
+			done, checkErr := before()
+			if checkErr != nil {
+				return fmt.Errorf("could not check before retrying: %w", checkErr)
+			}
+			if done {
+				return nil
+			}
+		}
+
+		err = fn()
+	}
+
+	return err
+}
+
+// wait pauses before a retry.
+func (p *Plugin) wait(delay time.Duration) {
+	if p.sleep != nil {
+		p.sleep(delay)
+		return
+	}
+
+	time.Sleep(delay)
+}
+
+// isTransient determines whether a failure may succeed when retried. The
+// registry rejecting a request is permanent, unless it failed on its side or
+// asked to slow down, while failing commands and connections are retried.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *registry.StatusError
+	if errors.As(err, &statusErr) {
+		return errors.Is(err, registry.ErrServer) || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+// versionPublished determines whether the version of the package is found in
+// the registry, which means an earlier publish that appeared to fail went
+// through.
+func (p *Plugin) versionPublished(npm *npmPackage) (bool, error) {
+	client, err := p.registryClient()
+	if err != nil {
+		return false, err
+	}
+
+	packument, err := client.Packument(p.context(), npm.Name)
+	if errors.Is(err, registry.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, found := packument.Versions[npm.Version]; found {
+		logrus.WithField("version", npm.Version).Info("Version found in the registry, publishing went through")
+		return true, nil
+	}
+
+	return false, nil
+}
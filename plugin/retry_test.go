@@ -0,0 +1,149 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(fmt.Errorf("exit status 1")))
+	assert.True(t, isTransient(&registry.StatusError{StatusCode: http.StatusBadGateway}))
+	assert.True(t, isTransient(&registry.StatusError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, isTransient(&registry.StatusError{StatusCode: http.StatusConflict}))
+	assert.False(t, isTransient(fmt.Errorf("lookup: %w", &registry.StatusError{StatusCode: http.StatusUnauthorized})))
+	assert.False(t, isTransient(context.Canceled))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var delays []time.Duration
+	p := initPlugin()
+	p.settings.Retries = 3
+	p.settings.RetryDelay = time.Second
+	p.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	attempts := 0
+	err := p.retry("test", func() error {
+		attempts++
+		return fmt.Errorf("exit status 1")
+	}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, delays)
+
+	// permanent failures are not retried
+	attempts = 0
+	err = p.retry("test", func() error {
+		attempts++
+		return &registry.StatusError{StatusCode: http.StatusForbidden}
+	}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+// initRetryPlugin creates a plugin publishing against a stand-in registry
+// which fails the given number of requests with a bad gateway before listing
+// the versions.
+func initRetryPlugin(t *testing.T, failures int, versions func() string) (*Plugin, *fakeRunner, *int) {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, `{"name": "my-awesome-package", "versions": {%s}}`, versions())
+	}))
+	t.Cleanup(server.Close)
+
+	runner := &fakeRunner{}
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.Retries = 2
+	p.network.Client = server.Client()
+	p.runner = runner
+	p.sleep = func(time.Duration) {}
+
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p, runner, &requests
+}
+
+func TestExecuteRetries(t *testing.T) {
+	// the version lookup and whoami are retried
+	p, runner, requests := initRetryPlugin(t, 1, func() string { return "" })
+	runner.failTimes("npm whoami", 1)
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"npm --version",
+			"npm whoami",
+			"npm whoami",
+			"npm publish",
+		}, runner.commands)
+		assert.Equal(t, 2, *requests)
+	}
+
+	// the publish is retried when the version was not published
+	p, runner, _ = initRetryPlugin(t, 0, func() string { return "" })
+	runner.failTimes("npm publish", 1)
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{"npm publish", "npm publish"}, runner.commands[len(runner.commands)-2:])
+	}
+
+	// the retries are used up
+	p, runner, _ = initRetryPlugin(t, 0, func() string { return "" })
+	runner.fail("npm publish")
+
+	err := p.Execute()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "could not publish package")
+	}
+	assert.Len(t, runner.commands, 5)
+}
+
+func TestExecuteRetryAlreadyPublished(t *testing.T) {
+	published := false
+	p, runner, _ := initRetryPlugin(t, 0, func() string {
+		if published {
+			return `"1.0.0": {}`
+		}
+		return ""
+	})
+
+	// the publish goes through but reports a failure
+	runner.fail("npm publish")
+	runner.inspect = func(cmd *exec.Cmd) {
+		published = published || strings.Join(cmd.Args, " ") == "npm publish"
+	}
+
+	if assert.Nil(t, p.Execute()) {
+		count := 0
+		for _, command := range runner.commands {
+			if command == "npm publish" {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"os/exec"
+)
+
+type (
+	// Runner runs the commands of the plugin once they have been prepared
+	// with their directory, environment and output.
+	Runner interface {
+		Run(cmd *exec.Cmd) error
+	}
+
+	// execRunner runs commands as child processes.
+	execRunner struct{}
+)
+
+// Run implements Runner.
+func (execRunner) Run(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/pack"
+)
+
+// fakeRunner records the commands it is asked to run instead of running them.
+type fakeRunner struct {
+	commands []string
+	dirs     []string
+	envs     [][]string
+
+	// failures is returned from running a command, keyed by the command line
+	failures map[string]error
+
+	// remaining limits how many more times a command fails when set
+	remaining map[string]int
+
+	// inspect is called with each command while it runs
+	inspect func(cmd *exec.Cmd)
+
+	// outputs is written to standard out, keyed by the command line
+	outputs map[string]string
+}
+
+// Run implements Runner.
+func (f *fakeRunner) Run(cmd *exec.Cmd) error {
+	command := strings.Join(cmd.Args, " ")
+	f.commands = append(f.commands, command)
+	f.dirs = append(f.dirs, cmd.Dir)
+	f.envs = append(f.envs, cmd.Env)
+	if f.inspect != nil {
+		f.inspect(cmd)
+	}
+	if output, ok := f.outputs[command]; ok {
+		fmt.Fprint(cmd.Stdout, output)
+	}
+
+	err := f.failures[command]
+	if n, ok := f.remaining[command]; ok {
+		if n == 0 {
+			return nil
+		}
+		f.remaining[command] = n - 1
+	}
+
+	return err
+}
+
+// packs makes pack commands write a tarball of the folder they run in to
+// their destination like the client does.
+func (f *fakeRunner) packs(t *testing.T) {
+	f.inspect = func(cmd *exec.Cmd) {
+		if len(cmd.Args) < 2 || cmd.Args[1] != "pack" {
+			return
+		}
+
+		tarball, err := pack.Pack(cmd.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file := filepath.Join(cmd.Args[len(cmd.Args)-1], tarball.Name+"-"+tarball.Version+".tgz")
+		if err := os.WriteFile(file, tarball.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// output makes the command write the output when it is run.
+func (f *fakeRunner) output(command, output string) {
+	if f.outputs == nil {
+		f.outputs = map[string]string{}
+	}
+	f.outputs[command] = output
+}
+
+// fail makes the command fail when it is run.
+func (f *fakeRunner) fail(command string) {
+	if f.failures == nil {
+		f.failures = map[string]error{}
+	}
+	f.failures[command] = fmt.Errorf("exit status 1")
+}
+
+// failTimes makes the command fail the first n times it is run.
+func (f *fakeRunner) failTimes(command string, n int) {
+	f.fail(command)
+	if f.remaining == nil {
+		f.remaining = map[string]int{}
+	}
+	f.remaining[command] = n
+}
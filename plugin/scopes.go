@@ -0,0 +1,151 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scopeConfig is the registry and optional credentials used for a scope.
+type scopeConfig struct {
+	Registry string `json:"registry"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// scopeRegexp matches the scope of a package name including the @.
+var scopeRegexp = regexp.MustCompile(`^@[a-z0-9-~][a-z0-9-._~]*$`)
+
+// parseScopes decodes the scoped registries from the settings. The scopes
+// are a JSON object mapping a scope to its registry and credentials.
+func (p *Plugin) parseScopes() error {
+	if p.settings.Scopes == "" {
+		return nil
+	}
+
+	configs := map[string]scopeConfig{}
+	if err := json.Unmarshal([]byte(p.settings.Scopes), &configs); err != nil {
+		return fmt.Errorf("invalid scopes: %w", err)
+	}
+
+	scopes := map[string]scopeConfig{}
+	for scope, config := range configs {
+		if !strings.HasPrefix(scope, "@") {
+			scope = "@" + scope
+		}
+		if !scopeRegexp.MatchString(scope) {
+			return fmt.Errorf("invalid scope %s", scope)
+		}
+		registry, err := url.Parse(config.Registry)
+		if err != nil {
+			return fmt.Errorf("invalid registry for scope %s: %w", scope, err)
+		}
+		if (registry.Scheme != "http" && registry.Scheme != "https") || registry.Host == "" {
+			return fmt.Errorf("scope %s requires a http or https registry", scope)
+		}
+		if config.Token == "" && (config.Username == "") != (config.Password == "") {
+			return fmt.Errorf("scope %s requires both a username and password", scope)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"scope":    scope,
+			"registry": config.Registry,
+		}).Info("Using scoped registry")
+
+		scopes[scope] = config
+	}
+
+	p.settings.scopes = scopes
+	return nil
+}
+
+// validateScope verifies that the registry configured for the scope of the
+// package is the registry the package is published to, as npm publishes
+// scoped packages to the registry of their scope.
+func (p *Plugin) validateScope(npm *npmPackage) error {
+	scope := packageScope(npm.Name)
+	if scope == "" {
+		return nil
+	}
+
+	config, ok := p.settings.scopes[scope]
+	if !ok {
+		return nil
+	}
+
+	registriesMatch, err := compareRegistries(config.Registry, p.settings.Registry)
+	if err != nil {
+		return fmt.Errorf(
+			"issue comparing the registries specified in drone yaml (%s) and scope %s: (%s)",
+			p.settings.Registry,
+			scope,
+			config.Registry,
+		)
+	}
+	if !registriesMatch && !p.settings.SkipRegistryValidation {
+		return fmt.Errorf("registry values do not match .drone.yml: %s scope %s: %s", p.settings.Registry, scope, config.Registry)
+	}
+
+	return nil
+}
+
+// packageScope returns the scope of the package name or an empty string for
+// unscoped packages.
+func packageScope(name string) string {
+	if !strings.HasPrefix(name, "@") {
+		return ""
+	}
+
+	scope, _, found := strings.Cut(name, "/")
+	if !found {
+		return ""
+	}
+
+	return scope
+}
+
+// npmrcContentsScopes creates the registry and credential lines for the
+// scoped registries.
+func npmrcContentsScopes(scopes map[string]scopeConfig) (string, error) {
+	var b strings.Builder
+	for _, scope := range sortedScopes(scopes) {
+		config := scopes[scope]
+		nerfDart, err := registryNerfDart(config.Registry)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&b, "\n%s:registry=%s", scope, config.Registry)
+
+		switch {
+		case config.Token != "":
+			fmt.Fprintf(&b, "\n%s:_authToken=%s", nerfDart, config.Token)
+		case config.Username != "":
+			fmt.Fprintf(&b, "\n%s:_auth=%s", nerfDart, basicAuth(config.Username, config.Password))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// sortedScopes returns the names of the scopes in a stable order.
+func sortedScopes(scopes map[string]scopeConfig) []string {
+	names := make([]string, 0, len(scopes))
+	for scope := range scopes {
+		names = append(names, scope)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScopes(t *testing.T) {
+	p := initPlugin()
+
+	p.settings.Scopes = `{"acme": {"registry": "https://npm.acme.com/", "token": "token"}}`
+	if assert.Nil(t, p.parseScopes()) {
+		assert.Equal(t, "https://npm.acme.com/", p.settings.scopes["@acme"].Registry)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"@Acme": {"registry": "https://npm.acme.com/"}}`,
+		`{"@acme": {"registry": "npm.acme.com"}}`,
+		`{"@acme": {"registry": "https://bad host/"}}`,
+		`{"@acme": {"registry": "https:///no-host"}}`,
+		`{"@acme": {"registry": "https://npm.acme.com/", "username": "user"}}`,
+	}
+	for _, scopes := range invalid {
+		p.settings.Scopes = scopes
+		assert.NotNil(t, p.parseScopes(), "%s should be invalid", scopes)
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	p := initPlugin()
+	p.settings.Scopes = `{"@acme": {"registry": "https://fakenpm.reg.org:443/good/path"}, "@other": {"registry": "https://registry.npmjs.org/"}}`
+	if !assert.Nil(t, p.parseScopes()) {
+		return
+	}
+
+	npm := p.settings.npm
+	npm.Name = "@acme/my-package"
+	assert.Nil(t, p.validateScope(npm))
+
+	npm.Name = "unscoped"
+	assert.Nil(t, p.validateScope(npm))
+
+	npm.Name = "@unknown/my-package"
+	assert.Nil(t, p.validateScope(npm))
+
+	npm.Name = "@other/my-package"
+	err := p.validateScope(npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "registry.npmjs.org")
+	}
+
+	p.settings.SkipRegistryValidation = true
+	assert.Nil(t, p.validateScope(npm))
+}
+
+func TestScopesRCContents(t *testing.T) {
+	scopes := map[string]scopeConfig{
+		"@public": {Registry: "https://registry.npmjs.org/"},
+		"@acme":   {Registry: "https://npm.acme.com:443/private", Token: "token"},
+		"@basic":  {Registry: "http://npm.basic.com:8080/", Username: "user", Password: "pass"},
+	}
+
+	actual, err := npmrcContentsScopes(scopes)
+	assert.Nil(t, err)
+	expected := "\n@acme:registry=https://npm.acme.com:443/private" +
+		"\n//npm.acme.com/private/:_authToken=token" +
+		"\n@basic:registry=http://npm.basic.com:8080/" +
+		"\n//npm.basic.com:8080/:_auth=dXNlcjpwYXNz" +
+		"\n@public:registry=https://registry.npmjs.org/"
+	assert.Equal(t, expected, actual)
+
+	actual, _ = npmrcContentsScopes(nil)
+	assert.Equal(t, "", actual)
+}
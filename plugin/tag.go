@@ -0,0 +1,88 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/drone-plugins/drone-npm/semver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// latestTag is the dist-tag npm installs by default.
+	latestTag = "latest"
+
+	// prereleaseTag is used for prerelease versions with a numeric identifier
+	// such as 1.0.0-0.
+	prereleaseTag = "next"
+)
+
+// parsePrereleaseTags decodes the mapping of prerelease identifiers to
+// dist-tags from the settings.
+func (p *Plugin) parsePrereleaseTags() error {
+	if p.settings.PrereleaseTags == "" {
+		return nil
+	}
+
+	tags := map[string]string{}
+	if err := json.Unmarshal([]byte(p.settings.PrereleaseTags), &tags); err != nil {
+		return fmt.Errorf("invalid prerelease tags %s: %w", p.settings.PrereleaseTags, err)
+	}
+
+	p.settings.prereleaseTags = tags
+	return nil
+}
+
+// distTag determines the dist-tag for the package. Snapshots always use the
+// snapshot tag and a configured tag, or else the tag of the publishConfig, is
+// always used otherwise. Prerelease versions are tagged from their first
+// prerelease identifier so they are never published as latest by accident.
+func (p *Plugin) distTag(npm *npmPackage) (string, error) {
+	version, err := semver.Parse(npm.Version)
+	if err != nil {
+		return "", err
+	}
+
+	tag := p.settings.Tag
+	if tag == "" {
+		tag = npm.Config.Tag
+	}
+	if p.settings.Snapshot {
+		tag = p.settings.SnapshotTag
+	} else if tag == "" && version.IsPrerelease() {
+		tag = p.prereleaseTag(version)
+
+		logrus.WithFields(logrus.Fields{
+			"version": npm.Version,
+			"tag":     tag,
+		}).Info("Using dist-tag derived from prerelease version")
+	}
+
+	if version.IsPrerelease() && (tag == "" || tag == latestTag) && p.settings.DisallowPrereleaseLatest {
+		return "", fmt.Errorf("prerelease version %s cannot be published with the %s tag", npm.Version, latestTag)
+	}
+
+	return tag, nil
+}
+
+// prereleaseTag maps the first prerelease identifier of the version to a
+// dist-tag.
+func (p *Plugin) prereleaseTag(version *semver.Version) string {
+	identifier := version.Prerelease[0]
+	if tag, ok := p.settings.prereleaseTags[identifier]; ok {
+		return tag
+	}
+
+	// numeric identifiers are not valid tags
+	if _, err := strconv.ParseUint(identifier, 10, 64); err == nil {
+		return prereleaseTag
+	}
+
+	return identifier
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistTag(t *testing.T) {
+	p := initPlugin()
+	npm := p.settings.npm
+
+	tests := []struct {
+		version string
+		tag     string
+	}{
+		{"1.0.0", ""},
+		{"2.0.0-beta.1", "beta"},
+		{"2.0.0-rc.0", "rc"},
+		{"2.0.0-next.3", "next"},
+		{"2.0.0-0", "next"},
+		{"2.0.0-alpha+build.1", "alpha"},
+	}
+
+	for _, test := range tests {
+		npm.Version = test.version
+		tag, err := p.distTag(npm)
+		assert.Nil(t, err)
+		assert.Equal(t, test.tag, tag, "tag for %s", test.version)
+	}
+
+	// A configured tag is always used
+	p.settings.Tag = "experimental"
+	npm.Version = "2.0.0-beta.1"
+	tag, err := p.distTag(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "experimental", tag)
+
+	npm.Version = "not-semver"
+	_, err = p.distTag(npm)
+	assert.NotNil(t, err)
+}
+
+func TestDistTagMapping(t *testing.T) {
+	p := initPlugin()
+	npm := p.settings.npm
+
+	p.settings.PrereleaseTags = `{"alpha": "next", "beta": "next", "rc": "latest"}`
+	if assert.Nil(t, p.parsePrereleaseTags()) {
+		npm.Version = "2.0.0-beta.1"
+		tag, err := p.distTag(npm)
+		assert.Nil(t, err)
+		assert.Equal(t, "next", tag)
+
+		npm.Version = "2.0.0-canary.1"
+		tag, err = p.distTag(npm)
+		assert.Nil(t, err)
+		assert.Equal(t, "canary", tag)
+
+		npm.Version = "2.0.0-rc.1"
+		tag, err = p.distTag(npm)
+		assert.Nil(t, err)
+		assert.Equal(t, "latest", tag)
+
+		p.settings.DisallowPrereleaseLatest = true
+		_, err = p.distTag(npm)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "2.0.0-rc.1")
+		}
+	}
+
+	p.settings.PrereleaseTags = `["beta"]`
+	assert.NotNil(t, p.parsePrereleaseTags())
+}
+
+func TestDistTagDisallowPrereleaseLatest(t *testing.T) {
+	p := initPlugin()
+	npm := p.settings.npm
+	p.settings.DisallowPrereleaseLatest = true
+
+	p.settings.Tag = "latest"
+	npm.Version = "2.0.0-beta.1"
+	_, err := p.distTag(npm)
+	assert.NotNil(t, err)
+
+	npm.Version = "2.0.0"
+	tag, err := p.distTag(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "latest", tag)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/sirupsen/logrus"
+)
+
+// validateTarball resolves the path or glob of the prebuilt tarball to
+// publish, which must match a single file. The tarball is published as is,
+// so settings that change the package are rejected.
+func (p *Plugin) validateTarball() error {
+	switch {
+	case p.settings.Workspaces:
+		return fmt.Errorf("a tarball cannot be published with workspaces")
+	case p.settings.Snapshot, p.settings.VersionFromTag:
+		return fmt.Errorf("the version of a tarball cannot be changed")
+	case p.settings.Client == yarnClient:
+		return fmt.Errorf("publishing a tarball is not supported by yarn")
+	}
+
+	matches, err := filepath.Glob(p.settings.Tarball)
+	if err != nil {
+		return fmt.Errorf("invalid tarball pattern %s: %w", p.settings.Tarball, err)
+	}
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no tarball matches %s", p.settings.Tarball)
+	case 1:
+	default:
+		return fmt.Errorf("%d tarballs match %s, expected one", len(matches), p.settings.Tarball)
+	}
+
+	// commands run in the folder so the path must not be relative
+	tarball, err := filepath.Abs(matches[0])
+	if err != nil {
+		return err
+	}
+
+	p.settings.tarball = tarball
+	return nil
+}
+
+// readTarballPackage reads the package file from within the tarball, in
+// place of the one in the folder. The integrity of the tarball is kept to
+// verify the registry serves exactly those bytes.
+func readTarballPackage(file, folder string) (*npmPackage, error) {
+	tarball, err := pack.Read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	npm := npmPackage{}
+	if err := json.Unmarshal(tarball.Manifest, &npm); err != nil {
+		return nil, err
+	}
+
+	// The files are checked by the contents policy instead of on disk
+	if problems := packageProblems(&npm); len(problems) > 0 {
+		return nil, fmt.Errorf("problems in %s:\n  %s", file, strings.Join(problems, "\n  "))
+	}
+
+	npm.folder = folder
+	npm.integrity = tarball.Integrity
+
+	// Set the default registry
+	if npm.Config.Registry == "" {
+		npm.Config.Registry = globalRegistry
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":      npm.Name,
+		"version":   npm.Version,
+		"path":      file,
+		"integrity": tarball.Integrity,
+	}).Info("Found package.json in tarball")
+
+	return &npm, nil
+}
+
+// packPackage creates the tarball to publish over HTTP, unless a prebuilt
+// tarball is published.
+func (p *Plugin) packPackage(npm *npmPackage) (*pack.Tarball, error) {
+	if p.settings.tarball != "" {
+		return pack.Read(p.settings.tarball)
+	}
+
+	return pack.Pack(npm.folder)
+}
+
+// packWithClient packs the package with the client into the directory, which
+// runs the lifecycle scripts of packing, and reads the created tarball.
+func (p *Plugin) packWithClient(npm *npmPackage, dir string) (*pack.Tarball, string, error) {
+	if err := p.runCommand(p.manager().packCommand(dir), npm.folder); err != nil {
+		return nil, "", fmt.Errorf("could not pack %s: %w", npm.Name, err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(matches) != 1 {
+		return nil, "", fmt.Errorf("expected one tarball from packing %s, found %d", npm.Name, len(matches))
+	}
+
+	tarball, err := pack.Read(matches[0])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tarball, matches[0], nil
+}
+
+// stageVersion packs the package with the client and replaces the version in
+// the package.json of the tarball, leaving the folder untouched. The returned
+// tarball is published in place of the folder.
+func (p *Plugin) stageVersion(npm *npmPackage, dir string) (string, error) {
+	tarball, file, err := p.packWithClient(npm, dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := setTarballVersion(tarball, npm.Version); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(file, tarball.Data, 0o600); err != nil {
+		return "", err
+	}
+
+	npm.integrity = tarball.Integrity
+	return file, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTarball packs a package into a tarball within the directory, as npm
+// pack does in a build step.
+func writeTarball(t *testing.T, dir, version string) string {
+	t.Helper()
+
+	folder := t.TempDir()
+	manifest := `{"name": "my-tarball-package", "version": "` + version + `"}`
+	if err := os.WriteFile(filepath.Join(folder, "package.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "index.js"), []byte("tested"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball, err := pack.Pack(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "my-tarball-package-"+version+".tgz")
+	if err := os.WriteFile(file, tarball.Data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestValidateTarball(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTarball(t, dir, "1.0.0")
+
+	p := initPlugin()
+	p.settings.Tarball = filepath.Join(dir, "*.tgz")
+	if assert.Nil(t, p.validateTarball()) {
+		assert.Equal(t, file, p.settings.tarball)
+	}
+
+	p.settings.Workspaces = true
+	assert.NotNil(t, p.validateTarball())
+
+	p.settings.Workspaces = false
+	p.settings.Snapshot = true
+	assert.NotNil(t, p.validateTarball())
+
+	p.settings.Snapshot = false
+	p.settings.Client = "yarn"
+	assert.NotNil(t, p.validateTarball())
+
+	// the pattern must match a single tarball
+	p.settings.Client = ""
+	p.settings.Tarball = filepath.Join(dir, "missing-*.tgz")
+	assert.NotNil(t, p.validateTarball())
+
+	writeTarball(t, dir, "1.1.0")
+	p.settings.Tarball = filepath.Join(dir, "*.tgz")
+	assert.NotNil(t, p.validateTarball())
+}
+
+func TestExecuteTarball(t *testing.T) {
+	p, runner := initExecutePlugin(t, http.StatusNotFound, "")
+	p.settings.Tarball = writeTarball(t, t.TempDir(), "1.0.0")
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the name and version are read from the tarball
+	assert.Equal(t, "my-tarball-package", p.settings.npm.Name)
+	assert.NotEmpty(t, p.settings.npm.integrity)
+
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, "npm publish "+p.settings.Tarball, runner.commands[len(runner.commands)-1])
+	}
+}
+
+func TestExecuteTarballAgainstRegistry(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	file := writeTarball(t, t.TempDir(), "1.0.0")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.VerifyPublish = true
+	p.settings.Tarball = file
+	p.network.Client = server.Client()
+
+	// exactly the bytes of the tarball are published
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		published, found := server.Tarball("my-tarball-package", "1.0.0")
+		if assert.True(t, found) {
+			assert.Equal(t, data, published)
+		}
+	}
+}
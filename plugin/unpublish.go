@@ -0,0 +1,153 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/semver"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// unpublishAction removes a published version of the package.
+	unpublishAction = "unpublish"
+
+	// unpublishWindow is how long after publishing the npm registry allows
+	// unpublishing a version.
+	unpublishWindow = 72 * time.Hour
+)
+
+// parseUnpublish verifies the settings of the unpublish action.
+func (p *Plugin) parseUnpublish() error {
+	if err := p.validateManagement(false); err != nil {
+		return err
+	}
+
+	if p.settings.UnpublishVersion != "" {
+		if _, err := semver.Parse(p.settings.UnpublishVersion); err != nil {
+			return fmt.Errorf("cannot unpublish %s: %w", p.settings.UnpublishVersion, err)
+		}
+	}
+
+	return nil
+}
+
+// manageUnpublish removes the version of the package from the registry. The
+// version must have been published within the unpublish window and removing
+// the only version, which removes the whole package, must be forced. When
+// requested the latest dist-tag moves back to the previous stable version.
+func (p *Plugin) manageUnpublish(npm *npmPackage) error {
+	version := p.settings.UnpublishVersion
+	if version == "" {
+		version = npm.Version
+	}
+
+	client, err := p.registryClient()
+	if err != nil {
+		return err
+	}
+
+	packument, err := p.lookupPackument(client, npm)
+	if err != nil {
+		return err
+	}
+
+	if _, found := packument.Versions[version]; !found {
+		return fmt.Errorf("version %s of %s is not published", version, npm.Name)
+	}
+
+	published, found := packument.Time[version]
+	if !found {
+		return fmt.Errorf("publish time of version %s of %s is unknown", version, npm.Name)
+	}
+	if age := time.Since(published); age > unpublishWindow {
+		return fmt.Errorf("version %s of %s was published %s ago, it can only be unpublished within %s",
+			version, npm.Name, age.Round(time.Minute), unpublishWindow)
+	}
+
+	whole := len(packument.Versions) == 1
+	if whole && !p.settings.UnpublishForce {
+		return fmt.Errorf("version %s is the only version of %s, unpublishing it removes the package and must be forced", version, npm.Name)
+	}
+
+	restore := ""
+	if p.settings.RestoreLatest && !whole && packument.DistTags[latestTag] == version {
+		restore = previousStable(packument, version)
+		if restore == "" {
+			logrus.WithField("name", npm.Name).Warn("No stable version to move the latest dist-tag to")
+		}
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"name":    npm.Name,
+		"version": version,
+		"package": whole,
+		"latest":  restore,
+	})
+
+	if p.settings.DryRun {
+		logger.Info("Dry run, not unpublishing")
+		return nil
+	}
+
+	logger.Info("Unpublishing version")
+	err = p.retry("unpublish", func() error {
+		if !p.settings.HTTPPublish {
+			return p.runCommand(p.manager().unpublishCommand(npm.Name, version, whole), npm.folder)
+		}
+		if whole {
+			return client.UnpublishPackage(p.context(), npm.Name)
+		}
+		return client.Unpublish(p.context(), npm.Name, version)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("could not unpublish %s: %w", version, err)
+	}
+
+	if restore == "" {
+		return nil
+	}
+
+	logger.Info("Moving the latest dist-tag to the previous stable version")
+	err = p.retry("dist-tag", func() error {
+		if p.settings.HTTPPublish {
+			return client.AddDistTag(p.context(), npm.Name, latestTag, restore)
+		}
+		return p.runCommand(p.manager().distTagAddCommand(npm.Name, restore, latestTag), npm.folder)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("could not move dist-tag %s to %s: %w", latestTag, restore, err)
+	}
+
+	return nil
+}
+
+// previousStable returns the highest published version other than the
+// removed one which is not a prerelease, or an empty string when there is
+// none.
+func previousStable(packument *registry.Packument, removed string) string {
+	var highest *semver.Version
+	stable := ""
+	for raw := range packument.Versions {
+		if raw == removed {
+			continue
+		}
+
+		version, err := semver.Parse(raw)
+		if err != nil || version.IsPrerelease() {
+			continue
+		}
+		if highest == nil || semver.Compare(version, highest) > 0 {
+			highest = version
+			stable = raw
+		}
+	}
+
+	return stable
+}
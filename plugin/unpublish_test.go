@@ -0,0 +1,153 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func initUnpublishServer(t *testing.T, versions ...string) *registrytest.Server {
+	t.Helper()
+
+	server := registrytest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddToken("token", "octocat")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	for _, version := range versions {
+		manifest := []byte(`{"name": "my-awesome-package", "version": "` + version + `"}`)
+		if err := client.Publish(context.TODO(), manifest, []byte(version), "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return server
+}
+
+func initUnpublishPlugin(server *registrytest.Server) (*Plugin, *fakeRunner) {
+	runner := &fakeRunner{}
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.SkipWhoami = true
+	p.settings.Action = "unpublish"
+	p.network.Client = server.Client()
+	p.runner = runner
+
+	return p, runner
+}
+
+func TestParseUnpublish(t *testing.T) {
+	p := initPlugin()
+	p.settings.Action = "unpublish"
+	assert.Nil(t, p.parseUnpublish())
+
+	p.settings.UnpublishVersion = "latest"
+	assert.NotNil(t, p.parseUnpublish())
+
+	p.settings.UnpublishVersion = "1.2.3"
+	assert.Nil(t, p.parseUnpublish())
+
+	p.settings.Client = "yarn"
+	assert.NotNil(t, p.parseUnpublish())
+
+	p.settings.Client = ""
+	p.settings.Workspaces = true
+	assert.NotNil(t, p.parseUnpublish())
+}
+
+func TestExecuteUnpublish(t *testing.T) {
+	server := initUnpublishServer(t, "0.9.0", "1.0.0-rc.1", "1.0.0")
+	p, runner := initUnpublishPlugin(server)
+	p.settings.RestoreLatest = true
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a dry run leaves the version alone
+	p.settings.DryRun = true
+	if assert.Nil(t, p.Execute()) {
+		for _, command := range runner.commands {
+			assert.NotContains(t, command, "unpublish")
+		}
+	}
+
+	// latest moves back to the previous stable version
+	runner.commands = nil
+	p.settings.DryRun = false
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, []string{
+			"npm unpublish my-awesome-package@1.0.0",
+			"npm dist-tag add my-awesome-package@0.9.0 latest",
+		}, runner.commands[len(runner.commands)-2:])
+	}
+
+	// versions published outside the window can't be unpublished
+	runner.commands = nil
+	server.SetTime("my-awesome-package", "1.0.0", time.Now().Add(-73*time.Hour))
+	assert.NotNil(t, p.Execute())
+
+	// unknown versions can't be unpublished
+	p.settings.UnpublishVersion = "2.0.0"
+	assert.NotNil(t, p.Execute())
+	for _, command := range runner.commands {
+		assert.NotContains(t, command, "unpublish")
+	}
+}
+
+func TestExecuteUnpublishOnlyVersion(t *testing.T) {
+	server := initUnpublishServer(t, "1.0.0")
+	p, runner := initUnpublishPlugin(server)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing the whole package must be forced
+	assert.NotNil(t, p.Execute())
+
+	p.settings.UnpublishForce = true
+	if assert.Nil(t, p.Execute()) {
+		assert.Equal(t, "npm unpublish my-awesome-package@1.0.0 --force", runner.commands[len(runner.commands)-1])
+	}
+}
+
+func TestExecuteUnpublishAgainstRegistry(t *testing.T) {
+	server := initUnpublishServer(t, "1.0.0", "1.1.0", "1.2.0")
+	p, _ := initUnpublishPlugin(server)
+	p.settings.HTTPPublish = true
+	p.settings.UnpublishVersion = "1.2.0"
+	p.settings.RestoreLatest = true
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.NotContains(t, pkg.Versions, "1.2.0")
+		assert.Equal(t, "1.1.0", pkg.DistTags["latest"])
+	}
+
+	// the only remaining version removes the whole package
+	p.settings.UnpublishForce = true
+	for _, version := range []string{"1.1.0", "1.0.0"} {
+		p.settings.UnpublishVersion = version
+		assert.Nil(t, p.Execute())
+	}
+
+	_, found := server.Packument("my-awesome-package")
+	assert.False(t, found)
+
+	// the unpublished package is published again
+	p.settings.Action = "publish"
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		_, found = server.Packument("my-awesome-package")
+		assert.True(t, found)
+	}
+}
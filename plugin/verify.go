@@ -0,0 +1,125 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultVerifyTimeout is how long to wait for a published version to be
+	// served.
+	defaultVerifyTimeout = 5 * time.Minute
+
+	// verifyInterval is the delay between looking up the published version.
+	verifyInterval = 5 * time.Second
+)
+
+// verifyPublished polls the registry until it serves the published version,
+// failing once the timeout passes. The integrity of the version must match
+// the tarball when the plugin packed it or the client reported it, otherwise
+// the version must only list an integrity.
+func (p *Plugin) verifyPublished(npm *npmPackage) error {
+	client, err := p.registryClient()
+	if err != nil {
+		return err
+	}
+
+	timeout := p.settings.VerifyTimeout
+	if timeout <= 0 {
+		timeout = defaultVerifyTimeout
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"name":      npm.Name,
+		"version":   npm.Version,
+		"integrity": npm.integrity,
+	})
+	logger.Info("Verifying the version is served by the registry")
+
+	var waited time.Duration
+	for {
+		done, err := p.checkPublished(client, npm)
+		if err != nil {
+			return err
+		}
+		if done {
+			logger.Info("Version is served by the registry")
+			return nil
+		}
+
+		if waited >= timeout {
+			return fmt.Errorf("version %s of %s was not served by the registry after %s", npm.Version, npm.Name, timeout)
+		}
+
+		logger.WithField("waited", waited).Debug("Version not served yet")
+		p.wait(verifyInterval)
+		waited += verifyInterval
+	}
+}
+
+// checkPublished determines whether the registry serves the version of the
+// package. Transient failures are treated as the version not being served
+// yet.
+func (p *Plugin) checkPublished(client *registry.Client, npm *npmPackage) (bool, error) {
+	packument, err := client.Packument(p.context(), npm.Name)
+	if errors.Is(err, registry.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		if isTransient(err) {
+			logrus.WithError(err).Debug("Could not look up the version")
+			return false, nil
+		}
+		return false, fmt.Errorf("could not look up the version: %w", err)
+	}
+
+	manifest, found := packument.Versions[npm.Version]
+	if !found || manifest.Dist.Integrity == "" {
+		return false, nil
+	}
+
+	if npm.integrity != "" && manifest.Dist.Integrity != npm.integrity {
+		return false, fmt.Errorf("version %s of %s is served with integrity %s, expected %s",
+			npm.Version, npm.Name, manifest.Dist.Integrity, npm.integrity)
+	}
+
+	return true, nil
+}
+
+// publishedIntegrity finds the integrity in the json npm publish reports,
+// which follows the output of the lifecycle scripts. An empty string is
+// returned when the output holds no integrity, such as from other clients.
+func publishedIntegrity(output []byte) string {
+	for end := len(output); end > 0; {
+		start := bytes.LastIndex(output[:end], []byte("{"))
+		if start < 0 {
+			break
+		}
+		end = start
+
+		// the report starts on a line of its own
+		if start > 0 && output[start-1] != '\n' {
+			continue
+		}
+
+		report := struct {
+			Integrity string `json:"integrity"`
+		}{}
+		if err := json.NewDecoder(bytes.NewReader(output[start:])).Decode(&report); err == nil && report.Integrity != "" {
+			return report.Integrity
+		}
+	}
+
+	return ""
+}
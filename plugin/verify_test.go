@@ -0,0 +1,158 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPublished(t *testing.T) {
+	// the version is served after the given number of lookups
+	served := 0
+	lookups := 0
+	integrity := "sha512-abc"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		switch {
+		case lookups == 2:
+			w.WriteHeader(http.StatusBadGateway)
+		case lookups <= served:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprintf(w, `{"name": "my-awesome-package", "versions": {"1.0.0": {"dist": {"integrity": %q}}}}`, integrity)
+		}
+	}))
+	defer server.Close()
+
+	var waited time.Duration
+	p := initPlugin()
+	p.settings.Registry = server.URL
+	p.settings.VerifyTimeout = time.Minute
+	p.network.Client = server.Client()
+	p.sleep = func(d time.Duration) { waited += d }
+
+	npm := &npmPackage{Name: "my-awesome-package", Version: "1.0.0", integrity: "sha512-abc"}
+
+	served = 3
+	if assert.Nil(t, p.verifyPublished(npm)) {
+		assert.Equal(t, 4, lookups)
+		assert.Equal(t, 3*verifyInterval, waited)
+	}
+
+	// the version is never served
+	lookups, waited, served = 0, 0, 100
+	err := p.verifyPublished(npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "was not served by the registry after 1m0s")
+		assert.Equal(t, time.Minute, waited)
+	}
+
+	// the version is served with another tarball
+	lookups, served = 0, 0
+	integrity = "sha512-other"
+	err = p.verifyPublished(npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "expected sha512-abc")
+	}
+
+	// without a known integrity any integrity is accepted
+	npm.integrity = ""
+	assert.Nil(t, p.verifyPublished(npm))
+}
+
+func TestExecuteVerifyPublish(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.HTTPPublish = true
+	p.settings.VerifyPublish = true
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		pkg, _ := server.Packument("my-awesome-package")
+		assert.NotEmpty(t, p.settings.npm.integrity)
+		assert.Contains(t, string(pkg.Versions["1.0.0"]), p.settings.npm.integrity)
+		assert.Equal(t, []string{
+			"GET /-/whoami",
+			"GET /my-awesome-package",
+			"PUT /my-awesome-package",
+			"GET /my-awesome-package",
+		}, server.Requests())
+	}
+}
+
+func TestPublishedIntegrity(t *testing.T) {
+	output := "> my-awesome-package@1.0.0 prepublishOnly\n> echo '{\"integrity\": \"script\"}'\n{\"integrity\": \"script\"} done\n" +
+		"{\n  \"id\": \"my-awesome-package@1.0.0\",\n  \"files\": [\n    {\"path\": \"package.json\"}\n  ],\n  \"integrity\": \"sha512-abc\"\n}\n"
+	assert.Equal(t, "sha512-abc", publishedIntegrity([]byte(output)))
+
+	assert.Equal(t, "", publishedIntegrity([]byte("+ my-awesome-package@1.0.0\n")))
+	assert.Equal(t, "", publishedIntegrity(nil))
+}
+
+func TestExecuteVerifyPublishCLI(t *testing.T) {
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	runner := &fakeRunner{}
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.SkipRegistryValidation = true
+	p.settings.SkipWhoami = true
+	p.settings.VerifyPublish = true
+	p.network.Client = server.Client()
+	p.runner = runner
+
+	// the client publishes the tarball and reports its integrity
+	tarball, err := pack.Pack("__test__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.inspect = func(cmd *exec.Cmd) {
+		if cmd.Args[1] == "publish" {
+			client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+			client.Publish(context.TODO(), tarball.Manifest, tarball.Data, "", "") //nolint:errcheck
+		}
+	}
+	runner.output("npm publish --json", fmt.Sprintf("{\n  \"integrity\": %q\n}\n", tarball.Integrity))
+
+	if assert.Nil(t, p.Validate()) && assert.Nil(t, p.Execute()) {
+		assert.Equal(t, tarball.Integrity, p.settings.npm.integrity)
+	}
+
+	// a different tarball than the one reported is served
+	server = registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	p.settings.Registry = server.URL
+	p.network.Client = server.Client()
+	runner.output("npm publish --json", "{\n  \"integrity\": \"sha512-other\"\n}\n")
+
+	if assert.Nil(t, p.Validate()) {
+		err := p.Execute()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "expected sha512-other")
+		}
+	}
+}
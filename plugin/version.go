@@ -0,0 +1,166 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/semver"
+	"github.com/sirupsen/logrus"
+)
+
+// shortSHALength is the length of the abbreviated commit in snapshot versions.
+const shortSHALength = 7
+
+// applyTagVersion compares the package version with the version of the git
+// tag being built. On a mismatch the version is either rejected or replaced,
+// depending on the settings.
+func (p *Plugin) applyTagVersion(npm *npmPackage) error {
+	if p.pipeline.SemVer.Error != "" {
+		return fmt.Errorf("could not parse git tag %s as a version: %s", p.pipeline.Build.Tag, p.pipeline.SemVer.Error)
+	}
+	if p.pipeline.SemVer.Version == "" {
+		return fmt.Errorf("no version available from the git tag, is this a tag build?")
+	}
+
+	version, err := semver.Parse(p.pipeline.SemVer.Version)
+	if err != nil {
+		return fmt.Errorf("invalid git tag version: %w", err)
+	}
+	tagVersion := version.String()
+
+	if tagVersion == npm.Version {
+		logrus.WithField("version", tagVersion).Info("Package version matches the git tag")
+		return nil
+	}
+
+	if !p.settings.RewriteVersion {
+		return fmt.Errorf("package.json version %s does not match the git tag version %s", npm.Version, tagVersion)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":    npm.Name,
+		"from":    npm.Version,
+		"version": tagVersion,
+	}).Info("Using the version from the git tag")
+
+	npm.setVersion(tagVersion)
+	return nil
+}
+
+// applySnapshotVersion replaces the package version with a unique prerelease
+// version built from the base version, build number and commit.
+func (p *Plugin) applySnapshotVersion(npm *npmPackage) error {
+	base, err := semver.Parse(npm.Version)
+	if err != nil {
+		return err
+	}
+
+	build := p.pipeline.Build.Number
+	sha := p.pipeline.Commit.SHA
+	if build == 0 || sha == "" {
+		return fmt.Errorf("snapshot versions require the build number and commit sha")
+	}
+	if len(sha) > shortSHALength {
+		sha = sha[:shortSHALength]
+	}
+
+	// numeric identifiers can't have leading zeros so prefix an all digit sha
+	if strings.Trim(sha, "0123456789") == "" {
+		sha = "g" + sha
+	}
+
+	version := fmt.Sprintf("%d.%d.%d-%s.%d.%s", base.Major, base.Minor, base.Patch, p.settings.SnapshotTag, build, sha)
+	if _, err := semver.Parse(version); err != nil {
+		return fmt.Errorf("invalid snapshot version: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":    npm.Name,
+		"from":    npm.Version,
+		"version": version,
+	}).Info("Using snapshot version")
+
+	npm.setVersion(version)
+	return nil
+}
+
+// setVersion replaces the version the package is published with. The
+// package.json in the folder is left alone, the version is only replaced in
+// the tarball being published.
+func (npm *npmPackage) setVersion(version string) {
+	npm.Version = version
+	npm.rewriteVersion = true
+}
+
+// setTarballVersion replaces the version in the package.json of the tarball.
+func setTarballVersion(tarball *pack.Tarball, version string) error {
+	modified, err := replacePackageVersion(tarball.Manifest, version)
+	if err != nil {
+		return fmt.Errorf("could not set version in package.json of %s: %w", tarball.Name, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"name":    tarball.Name,
+		"from":    tarball.Version,
+		"version": version,
+	}).Info("Replacing the version in the package tarball")
+
+	return tarball.SetManifest(modified)
+}
+
+// replacePackageVersion replaces the value of the top level version field in
+// the package.json contents while keeping the remaining formatting intact.
+func replacePackageVersion(data []byte, version string) ([]byte, error) {
+	value, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("package.json is not an object")
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key, _ := tok.(string)
+		start := dec.InputOffset()
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if key != "version" {
+			continue
+		}
+
+		// the raw value excludes the separator so locate it after the key
+		end := dec.InputOffset()
+		offset := bytes.Index(data[start:end], raw)
+		if offset < 0 {
+			return nil, fmt.Errorf("could not locate version")
+		}
+		offset += int(start)
+
+		var b bytes.Buffer
+		b.Write(data[:offset])
+		b.Write(value)
+		b.Write(data[offset+len(raw):])
+
+		return b.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("no version field present")
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/drone-plugins/drone-npm/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplacePackageVersion(t *testing.T) {
+	original := "{\n  \"name\": \"my-package\",\n  \"nested\": {\"version\": \"0.0.1\"},\n  \"version\" :  \"1.0.0\",\n  \"main\": \"index.js\"\n}\n"
+	expected := "{\n  \"name\": \"my-package\",\n  \"nested\": {\"version\": \"0.0.1\"},\n  \"version\" :  \"2.0.0-beta.1\",\n  \"main\": \"index.js\"\n}\n"
+
+	actual, err := replacePackageVersion([]byte(original), "2.0.0-beta.1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, expected, string(actual))
+	}
+
+	_, err = replacePackageVersion([]byte(`{"name": "my-package"}`), "1.0.0")
+	assert.NotNil(t, err)
+
+	_, err = replacePackageVersion([]byte(`["version"]`), "1.0.0")
+	assert.NotNil(t, err)
+}
+
+func TestApplyTagVersion(t *testing.T) {
+	p := initPlugin()
+	npm := p.settings.npm
+
+	// Not a tag build
+	err := p.applyTagVersion(npm)
+	assert.NotNil(t, err)
+
+	p.pipeline.SemVer.Error = "invalid"
+	err = p.applyTagVersion(npm)
+	assert.NotNil(t, err)
+
+	// Matching versions
+	p.pipeline.SemVer.Error = ""
+	p.pipeline.SemVer.Version = "1.33.7"
+	err = p.applyTagVersion(npm)
+	assert.Nil(t, err)
+	assert.False(t, npm.rewriteVersion)
+
+	// Mismatched versions
+	p.pipeline.SemVer.Version = "1.34.0"
+	err = p.applyTagVersion(npm)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "1.34.0")
+	}
+	assert.Equal(t, "1.33.7", npm.Version)
+
+	p.settings.RewriteVersion = true
+	err = p.applyTagVersion(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.34.0", npm.Version)
+	assert.True(t, npm.rewriteVersion)
+}
+
+func TestPublishReplacedVersion(t *testing.T) {
+	original := `{"name": "my-package", "version": "1.0.0"}`
+	dir := writeTestFiles(t, map[string]string{"package.json": original, "index.js": "index"})
+
+	runner := &fakeRunner{}
+	runner.packs(t)
+	packs := runner.inspect
+
+	var published *pack.Tarball
+	runner.inspect = func(cmd *exec.Cmd) {
+		packs(cmd)
+		if cmd.Args[1] == "publish" {
+			published, _ = pack.Read(cmd.Args[2])
+		}
+	}
+
+	p := initPlugin()
+	p.runner = runner
+	npm := &npmPackage{Name: "my-package", Version: "1.0.0", folder: dir}
+	npm.setVersion("2.0.0")
+
+	// the client packs the folder and publishes the tarball with the version
+	if assert.Nil(t, p.publish(npm)) {
+		assert.Len(t, runner.commands, 2)
+		assert.True(t, strings.HasPrefix(runner.commands[0], "npm pack --pack-destination "))
+		assert.True(t, strings.HasPrefix(runner.commands[1], "npm publish "))
+		assert.True(t, strings.HasSuffix(runner.commands[1], "my-package-1.0.0.tgz"))
+
+		if assert.NotNil(t, published) {
+			assert.Equal(t, "2.0.0", published.Version)
+			assert.Equal(t, published.Integrity, npm.integrity)
+		}
+	}
+
+	// the package.json is never modified
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	assert.Equal(t, original, string(data))
+}
+
+func TestPublishReplacedVersionHTTP(t *testing.T) {
+	original := `{"name": "my-package", "version": "1.0.0"}`
+	dir := writeTestFiles(t, map[string]string{"package.json": original, "index.js": "index"})
+
+	server := registrytest.NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Registry = server.URL
+	p.settings.HTTPPublish = true
+	p.network.Client = server.Client()
+	npm := &npmPackage{Name: "my-package", Version: "1.0.0", folder: dir}
+	npm.setVersion("2.0.0")
+
+	if assert.Nil(t, p.publish(npm)) {
+		pkg, _ := server.Packument("my-package")
+		assert.Contains(t, pkg.Versions, "2.0.0")
+		assert.NotContains(t, pkg.Versions, "1.0.0")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	assert.Equal(t, original, string(data))
+}
+
+func TestApplySnapshotVersion(t *testing.T) {
+	p := initPlugin()
+	p.settings.Snapshot = true
+	p.settings.SnapshotTag = defaultSnapshotTag
+	npm := p.settings.npm
+	npm.Version = "1.4.0-beta.2"
+
+	// Missing build information
+	err := p.applySnapshotVersion(npm)
+	assert.NotNil(t, err)
+
+	p.pipeline.Build.Number = 42
+	p.pipeline.Commit.SHA = "4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e"
+	err = p.applySnapshotVersion(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.4.0-canary.42.4d5e6f7", npm.Version)
+	assert.True(t, npm.rewriteVersion)
+
+	tag, err := p.distTag(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "canary", tag)
+
+	// The version is unique so the registry is not consulted
+	publish, err := p.shouldPublishPackage(npm)
+	assert.Nil(t, err)
+	assert.True(t, publish)
+
+	// An all digit sha is not a valid prerelease identifier
+	npm.Version = "1.4.0"
+	p.pipeline.Commit.SHA = "0123456789"
+	err = p.applySnapshotVersion(npm)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.4.0-canary.42.g0123456", npm.Version)
+}
+
+func TestExecuteSnapshot(t *testing.T) {
+	folder := writeTestFiles(t, map[string]string{
+		"package.json": `{"name": "my-awesome-package", "version": "1.4.0"}`,
+	})
+
+	p, runner := initExecutePlugin(t, http.StatusNotFound, "")
+	runner.packs(t)
+	p.settings.SkipWhoami = true
+	p.settings.Folder = folder
+	p.settings.Snapshot = true
+	p.pipeline.Build.Number = 42
+	p.pipeline.Commit.SHA = "4d5e6f7"
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Nil(t, p.Execute()) {
+		command := runner.commands[len(runner.commands)-1]
+		assert.True(t, strings.HasPrefix(command, "npm publish "))
+		assert.True(t, strings.HasSuffix(command, ".tgz --tag canary"))
+	}
+
+	data, _ := os.ReadFile(filepath.Join(folder, "package.json"))
+	assert.Equal(t, `{"name": "my-awesome-package", "version": "1.4.0"}`, string(data))
+
+	// yarn can't publish a tarball with the replaced version
+	p.settings.Client = yarnClient
+	assert.NotNil(t, p.Validate())
+}
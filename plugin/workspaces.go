@@ -0,0 +1,292 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/pack"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// npmWorkspaces holds the workspaces globs of a package.json which can
+	// either be an array or an object with a packages array.
+	npmWorkspaces []string
+
+	// workspaceResult records the outcome of publishing a workspace package.
+	workspaceResult struct {
+		npm    *npmPackage
+		status string
+	}
+)
+
+const (
+	statusPublished = "published"
+	statusSkipped   = "skipped"
+	statusFailed    = "failed"
+	statusPending   = "not attempted"
+)
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *npmWorkspaces) UnmarshalJSON(data []byte) error {
+	var globs []string
+	if err := json.Unmarshal(data, &globs); err == nil {
+		*w = globs
+		return nil
+	}
+
+	var config struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("workspaces must be an array or an object with packages")
+	}
+
+	*w = config.Packages
+	return nil
+}
+
+// publishWorkspace publishes every package in the workspace in dependency
+// order and logs a summary once done. Publishing stops at the first failure so
+// dependents are never published against a missing dependency.
+func (p *Plugin) publishWorkspace() error {
+	results := make([]workspaceResult, len(p.settings.workspace))
+	for i, npm := range p.settings.workspace {
+		results[i] = workspaceResult{npm: npm, status: statusPending}
+	}
+
+	var err error
+	for i := range results {
+		npm := results[i].npm
+		logrus.WithFields(logrus.Fields{
+			"name":    npm.Name,
+			"version": npm.Version,
+			"path":    npm.folder,
+		}).Info("Processing workspace package")
+
+		var published bool
+		if published, err = p.publishPackage(npm); err != nil {
+			results[i].status = statusFailed
+			err = fmt.Errorf("%s: %w", npm.Name, err)
+			break
+		}
+
+		if published {
+			results[i].status = statusPublished
+		} else {
+			results[i].status = statusSkipped
+		}
+	}
+
+	for _, result := range results {
+		logrus.WithFields(logrus.Fields{
+			"name":    result.npm.Name,
+			"version": result.npm.Version,
+			"status":  result.status,
+		}).Info("Workspace summary")
+	}
+
+	return err
+}
+
+// readWorkspaces discovers the public packages matched by the workspaces in
+// the package.json in folder, ordered so that each package comes after the
+// workspace packages it depends on.
+func readWorkspaces(folder string) ([]*npmPackage, error) {
+	packagePath := path.Join(folder, "package.json")
+	file, err := os.ReadFile(packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read package.json at %s: %w", packagePath, err)
+	}
+
+	root := struct {
+		Workspaces npmWorkspaces `json:"workspaces"`
+	}{}
+	if err := json.Unmarshal(file, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Workspaces) == 0 {
+		return nil, fmt.Errorf("no workspaces present in %s", packagePath)
+	}
+
+	folders, err := matchWorkspaces(folder, root.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []*npmPackage
+	for _, dir := range folders {
+		npm, file, err := parsePackageFile(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid package.json: %w", err)
+		}
+
+		if npm.Private {
+			logrus.WithField("name", npm.Name).Info("Skipping private workspace package")
+			continue
+		}
+
+		if err := checkPackageFile(npm, file, true); err != nil {
+			return nil, fmt.Errorf("invalid package.json: %w", err)
+		}
+
+		packages = append(packages, npm)
+	}
+
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("no public packages found in workspaces")
+	}
+
+	return sortWorkspaces(packages)
+}
+
+// matchWorkspaces returns the folders below root containing a package.json
+// that match the workspaces globs. Globs starting with ! exclude folders.
+func matchWorkspaces(root string, globs []string) ([]string, error) {
+	var include, exclude []string
+	for _, glob := range globs {
+		negate := strings.HasPrefix(glob, "!")
+		glob = path.Clean(strings.TrimPrefix(strings.TrimPrefix(glob, "!"), "./"))
+
+		if negate {
+			exclude = append(exclude, glob)
+		} else {
+			include = append(include, glob)
+		}
+	}
+
+	var folders []string
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "node_modules" || d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !matchesAny(include, rel) || matchesAny(exclude, rel) {
+			return nil
+		}
+
+		if _, err := os.Stat(filepath.Join(file, "package.json")); err == nil {
+			folders = append(folders, file)
+		}
+
+		return nil
+	})
+
+	return folders, err
+}
+
+func matchesAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if pack.Match(glob, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortWorkspaces orders the packages topologically by their dependencies on
+// each other. Packages without an ordering constraint are sorted by name.
+func sortWorkspaces(packages []*npmPackage) ([]*npmPackage, error) {
+	byName := map[string]*npmPackage{}
+	for _, npm := range packages {
+		if _, found := byName[npm.Name]; found {
+			return nil, fmt.Errorf("multiple workspace packages named %s", npm.Name)
+		}
+		byName[npm.Name] = npm
+	}
+
+	// count the internal dependencies of each package and track the reverse
+	// edges so dependents can be released once a package is ordered
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, npm := range packages {
+		pending[npm.Name] = 0
+		for _, dep := range npm.internalDependencies(byName) {
+			pending[npm.Name]++
+			dependents[dep] = append(dependents[dep], npm.Name)
+		}
+	}
+
+	var ready []string
+	for name, count := range pending {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	var sorted []*npmPackage
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, byName[name])
+
+		for _, dependent := range dependents[name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(sorted) != len(packages) {
+		var cycle []string
+		for name, count := range pending {
+			if count > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+
+		return nil, fmt.Errorf("dependency cycle between workspace packages: %s", strings.Join(cycle, ", "))
+	}
+
+	return sorted, nil
+}
+
+// internalDependencies returns the names of the packages in the workspace the
+// package depends on. Development dependencies are not needed by consumers of
+// the published package so they don't constrain the order.
+func (npm *npmPackage) internalDependencies(workspace map[string]*npmPackage) []string {
+	seen := map[string]bool{}
+	var deps []string
+
+	for _, group := range []map[string]string{
+		npm.Dependencies,
+		npm.PeerDependencies,
+		npm.OptionalDependencies,
+	} {
+		for name := range group {
+			if _, found := workspace[name]; found && name != npm.Name && !seen[name] {
+				seen[name] = true
+				deps = append(deps, name)
+			}
+		}
+	}
+
+	return deps
+}
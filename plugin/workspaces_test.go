@@ -0,0 +1,147 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func packageNames(packages []*npmPackage) []string {
+	var names []string
+	for _, npm := range packages {
+		names = append(names, npm.Name)
+	}
+	return names
+}
+
+func initWorkspaceFiles(t *testing.T) string {
+	return writeTestFiles(t, map[string]string{
+		"package.json":              `{"name": "root", "private": true, "workspaces": ["packages/*", "tools/**", "!tools/ignored"]}`,
+		"packages/app/package.json": `{"name": "@acme/app", "version": "1.0.0", "dependencies": {"@acme/core": "^1.0.0", "left-pad": "1.0.0"}}`,
+		"packages/core/package.json": `{"name": "@acme/core", "version": "1.0.0",
+			"peerDependencies": {"@acme/types": "*"}, "devDependencies": {"@acme/app": "*"}}`,
+		"packages/private/package.json":     `{"name": "@acme/private", "version": "1.0.0", "private": true}`,
+		"packages/empty/README.md":          "no package here",
+		"tools/nested/types/package.json":   `{"name": "@acme/types", "version": "1.0.0"}`,
+		"tools/ignored/package.json":        `{"name": "@acme/ignored", "version": "1.0.0"}`,
+		"tools/node_modules/x/package.json": `{"name": "x", "version": "1.0.0"}`,
+	})
+}
+
+func TestReadWorkspaces(t *testing.T) {
+	dir := initWorkspaceFiles(t)
+
+	packages, err := readWorkspaces(dir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"@acme/types", "@acme/core", "@acme/app"}, packageNames(packages))
+		assert.Equal(t, filepath.Join(dir, "packages", "app"), packages[2].folder)
+	}
+
+	// Object form of the workspaces
+	dir = writeTestFiles(t, map[string]string{
+		"package.json":            `{"name": "root", "workspaces": {"packages": ["./packages/*"]}}`,
+		"packages/a/package.json": `{"name": "a", "version": "1.0.0"}`,
+	})
+	packages, err = readWorkspaces(dir)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"a"}, packageNames(packages))
+	}
+
+	// Missing workspaces
+	dir = writeTestFiles(t, map[string]string{
+		"package.json": `{"name": "root", "version": "1.0.0"}`,
+	})
+	_, err = readWorkspaces(dir)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "no workspaces")
+	}
+
+	// Invalid workspace package
+	dir = writeTestFiles(t, map[string]string{
+		"package.json":            `{"name": "root", "workspaces": ["packages/*"]}`,
+		"packages/a/package.json": `{"name": "a"}`,
+	})
+	_, err = readWorkspaces(dir)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "version")
+	}
+}
+
+func TestSortWorkspacesCycle(t *testing.T) {
+	packages := []*npmPackage{
+		{Name: "a", Dependencies: map[string]string{"b": "*"}},
+		{Name: "b", OptionalDependencies: map[string]string{"a": "*"}},
+		{Name: "c"},
+	}
+
+	_, err := sortWorkspaces(packages)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "cycle between workspace packages: a, b")
+	}
+
+	_, err = sortWorkspaces([]*npmPackage{{Name: "a"}, {Name: "a"}})
+	assert.NotNil(t, err)
+}
+
+func TestPublishWorkspace(t *testing.T) {
+	var published []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		switch {
+		case r.Method == http.MethodGet && name == "@acme/types":
+			w.Write([]byte(`{"name": "@acme/types", "versions": {"1.0.0": {}}}`)) //nolint:errcheck
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut && name == "@acme/app":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			published = append(published, name)
+		}
+	}))
+	defer server.Close()
+
+	p := initPlugin()
+	p.settings.Token = "token"
+	p.settings.Folder = initWorkspaceFiles(t)
+	p.settings.Registry = server.URL
+	p.settings.FailOnVersionConflict = false
+	p.settings.HTTPPublish = true
+	p.settings.Workspaces = true
+	p.settings.SkipRegistryValidation = true
+	p.network.Client = server.Client()
+
+	if assert.Nil(t, p.Validate()) {
+		err := p.publishWorkspace()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "@acme/app")
+		}
+		assert.Equal(t, []string{"@acme/core"}, published)
+	}
+}
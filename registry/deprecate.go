@@ -0,0 +1,69 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Deprecate sets the deprecation message of the versions of the package,
+// which are keyed by version. An empty message undeprecates the version.
+// The whole packument is updated at once, as the npm CLI does.
+func (c *Client) Deprecate(ctx context.Context, name string, messages map[string]string) error {
+	req, err := c.newRequest(ctx, http.MethodGet, PackagePath(name)+"?write=true", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// the document is kept raw so fields this client doesn't know about are
+	// sent back unchanged
+	doc := map[string]json.RawMessage{}
+	if err := c.do(req, &doc); err != nil {
+		return err
+	}
+
+	versions := map[string]map[string]json.RawMessage{}
+	if err := json.Unmarshal(doc["versions"], &versions); err != nil {
+		return fmt.Errorf("%s %s: could not decode versions: %w", req.Method, req.URL.Redacted(), err)
+	}
+
+	for version, message := range messages {
+		manifest, found := versions[version]
+		if !found {
+			return fmt.Errorf("version %s of %s is not published", version, name)
+		}
+
+		if message == "" {
+			delete(manifest, "deprecated")
+			continue
+		}
+		if manifest["deprecated"], err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+
+	if doc["versions"], err = json.Marshal(versions); err != nil {
+		return err
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	req, err = c.newRequest(ctx, http.MethodPut, PackagePath(name), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("npm-command", "deprecate")
+
+	return c.do(req, nil)
+}
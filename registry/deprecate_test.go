@@ -0,0 +1,49 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecate(t *testing.T) {
+	var requests []string
+	var doc map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.Method == http.MethodPut {
+			json.NewDecoder(r.Body).Decode(&doc) //nolint:errcheck
+			w.Write([]byte(`{"ok": true}`))      //nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"_id": "@acme/my-package", "_rev": "3-abc", "name": "@acme/my-package", "versions": {` + //nolint:errcheck
+			`"1.0.0": {"version": "1.0.0", "deprecated": "old"}, "1.1.0": {"version": "1.1.0"}}}`))
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+	err := client.Deprecate(context.TODO(), "@acme/my-package", map[string]string{"1.0.0": "", "1.1.0": "vulnerable"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{
+			"GET /@acme%2Fmy-package?write=true",
+			"PUT /@acme%2Fmy-package",
+		}, requests)
+		assert.Equal(t, "3-abc", doc["_rev"])
+
+		versions := doc["versions"].(map[string]interface{})
+		assert.NotContains(t, versions["1.0.0"], "deprecated")
+		assert.Equal(t, "vulnerable", versions["1.1.0"].(map[string]interface{})["deprecated"])
+	}
+
+	err = client.Deprecate(context.TODO(), "@acme/my-package", map[string]string{"2.0.0": "vulnerable"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// DistTagPath returns the path of the dist-tag of the package relative to
+// the registry root.
+func DistTagPath(name, tag string) string {
+	return "/-/package" + PackagePath(name) + "/dist-tags/" + url.PathEscape(tag)
+}
+
+// AddDistTag points the dist-tag of the package at the version, moving it
+// when the tag already exists.
+func (c *Client) AddDistTag(ctx context.Context, name, tag, version string) error {
+	body, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, DistTagPath(name, tag), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("npm-command", "dist-tag")
+
+	return c.do(req, nil)
+}
+
+// RemoveDistTag removes the dist-tag of the package. If the tag does not
+// exist the returned error matches ErrNotFound.
+func (c *Client) RemoveDistTag(ctx context.Context, name, tag string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, DistTagPath(name, tag), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("npm-command", "dist-tag")
+
+	return c.do(req, nil)
+}
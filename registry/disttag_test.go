@@ -0,0 +1,52 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistTags(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+
+		if r.Method == http.MethodDelete && path == "/-/package/my-package/dist-tags/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+
+	err := client.AddDistTag(context.TODO(), "@acme/my-package", "latest", "1.5.0")
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.MethodPut, method)
+		assert.Equal(t, "/-/package/@acme%2Fmy-package/dist-tags/latest", path)
+		assert.Equal(t, `"1.5.0"`, body)
+	}
+
+	err = client.RemoveDistTag(context.TODO(), "@acme/my-package", "next")
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.MethodDelete, method)
+		assert.Equal(t, "/-/package/@acme%2Fmy-package/dist-tags/next", path)
+	}
+
+	err = client.RemoveDistTag(context.TODO(), "my-package", "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// OIDCExchangePath returns the path of the token exchange for the package
+// relative to the registry root.
+func OIDCExchangePath(name string) string {
+	return "/-/npm/v1/oidc/token/exchange/package" + PackagePath(name)
+}
+
+// ExchangeOIDCToken exchanges the OIDC ID token the Client authenticates with
+// for a short-lived token allowed to publish the named package, as set up for
+// trusted publishing in the registry.
+func (c *Client) ExchangeOIDCToken(ctx context.Context, name string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, OIDCExchangePath(name), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res := struct {
+		Token string `json:"token"`
+	}{}
+	if err := c.do(req, &res); err != nil {
+		return "", err
+	}
+	if res.Token == "" {
+		return "", fmt.Errorf("%s %s: no token in response", req.Method, req.URL.Redacted())
+	}
+
+	return res.Token, nil
+}
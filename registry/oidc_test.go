@@ -0,0 +1,56 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeOIDCToken(t *testing.T) {
+	var method, path, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		authorization = r.Header.Get("Authorization")
+
+		switch authorization {
+		case "Bearer id-token":
+			w.Write([]byte(`{"token": "npm_short_lived"}`)) //nolint:errcheck
+		case "Bearer empty":
+			w.Write([]byte(`{}`)) //nolint:errcheck
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "no trusted publisher matches the token"}`)) //nolint:errcheck
+		}
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "id-token"}, server.Client())
+	token, err := client.ExchangeOIDCToken(context.TODO(), "@acme/my-package")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "npm_short_lived", token)
+		assert.Equal(t, http.MethodPost, method)
+		assert.Equal(t, "/-/npm/v1/oidc/token/exchange/package/@acme%2Fmy-package", path)
+		assert.Equal(t, "Bearer id-token", authorization)
+	}
+
+	client, _ = New(server.URL, Auth{Token: "empty"}, server.Client())
+	_, err = client.ExchangeOIDCToken(context.TODO(), "my-package")
+	assert.NotNil(t, err)
+
+	client, _ = New(server.URL, Auth{Token: "untrusted"}, server.Client())
+	_, err = client.ExchangeOIDCToken(context.TODO(), "my-package")
+	if assert.NotNil(t, err) {
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+		assert.Contains(t, err.Error(), "no trusted publisher")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type (
+	// publishDocument is the body of the PUT request used to publish a
+	// version, mirroring what the npm CLI sends.
+	publishDocument struct {
+		ID          string                     `json:"_id"`
+		Name        string                     `json:"name"`
+		Description string                     `json:"description,omitempty"`
+		DistTags    map[string]string          `json:"dist-tags"`
+		Versions    map[string]json.RawMessage `json:"versions"`
+		Access      string                     `json:"access,omitempty"`
+		Attachments map[string]attachment      `json:"_attachments"`
+	}
+
+	// attachment is a base64 encoded tarball within a publishDocument.
+	attachment struct {
+		ContentType string `json:"content_type"`
+		Data        string `json:"data"`
+		Length      int    `json:"length"`
+	}
+)
+
+// Publish uploads the tarball for the package described by the package.json
+// contents in manifest and points the dist-tag at the new version. When tag
+// is empty the version is tagged as latest.
+func (c *Client) Publish(ctx context.Context, manifest, tarball []byte, tag, access string) error {
+	doc, err := c.publishDocument(manifest, tarball, tag, access)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, PackagePath(doc.Name), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("npm-command", "publish")
+
+	return c.do(req, nil)
+}
+
+func (c *Client) publishDocument(manifest, tarball []byte, tag, access string) (*publishDocument, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(manifest, &fields); err != nil {
+		return nil, fmt.Errorf("could not parse package.json: %w", err)
+	}
+
+	pkg := struct {
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	}{}
+	if err := json.Unmarshal(manifest, &pkg); err != nil {
+		return nil, fmt.Errorf("could not parse package.json: %w", err)
+	}
+	if pkg.Name == "" || pkg.Version == "" {
+		return nil, fmt.Errorf("package.json requires a name and version to publish")
+	}
+
+	if tag == "" {
+		tag = "latest"
+	}
+
+	sha1Sum := sha1.Sum(tarball) //nolint:gosec
+	sha512Sum := sha512.Sum512(tarball)
+	tarballName := fmt.Sprintf("%s-%s.tgz", pkg.Name, pkg.Version)
+
+	dist, err := json.Marshal(Dist{
+		Shasum:    hex.EncodeToString(sha1Sum[:]),
+		Integrity: "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:]),
+		Tarball:   fmt.Sprintf("%s/%s/-/%s", c.base, pkg.Name, tarballName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	id, err := json.Marshal(pkg.Name + "@" + pkg.Version)
+	if err != nil {
+		return nil, err
+	}
+	fields["_id"] = id
+	fields["dist"] = dist
+
+	version, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &publishDocument{
+		ID:          pkg.Name,
+		Name:        pkg.Name,
+		Description: pkg.Description,
+		DistTags:    map[string]string{tag: pkg.Version},
+		Versions:    map[string]json.RawMessage{pkg.Version: version},
+		Access:      access,
+		Attachments: map[string]attachment{
+			tarballName: {
+				ContentType: "application/octet-stream",
+				Data:        base64.StdEncoding.EncodeToString(tarball),
+				Length:      len(tarball),
+			},
+		},
+	}, nil
+}
+
+// Whoami returns the username the credentials belong to.
+func (c *Client) Whoami(ctx context.Context) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/-/whoami", nil)
+	if err != nil {
+		return "", err
+	}
+
+	res := struct {
+		Username string `json:"username"`
+	}{}
+	if err := c.do(req, &res); err != nil {
+		return "", err
+	}
+
+	return res.Username, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	var method, path, contentType string
+	var doc struct {
+		Name     string            `json:"name"`
+		DistTags map[string]string `json:"dist-tags"`
+		Access   string            `json:"access"`
+		Versions map[string]struct {
+			ID     string `json:"_id"`
+			Author string `json:"author"`
+			Dist   Dist   `json:"dist"`
+		} `json:"versions"`
+		Attachments map[string]attachment `json:"_attachments"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&doc) //nolint:errcheck
+		w.Write([]byte(`{"ok": true}`))      //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+	manifest := []byte(`{"name": "@acme/my-package", "version": "1.2.3", "author": "Your Name"}`)
+	tarball := []byte("not really a tarball")
+
+	err := client.Publish(context.TODO(), manifest, tarball, "", "public")
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.MethodPut, method)
+		assert.Equal(t, "/@acme%2Fmy-package", path)
+		assert.Equal(t, "application/json", contentType)
+		assert.Equal(t, "@acme/my-package", doc.Name)
+		assert.Equal(t, map[string]string{"latest": "1.2.3"}, doc.DistTags)
+		assert.Equal(t, "public", doc.Access)
+
+		version := doc.Versions["1.2.3"]
+		assert.Equal(t, "@acme/my-package@1.2.3", version.ID)
+		assert.Equal(t, "Your Name", version.Author)
+		assert.Equal(t, "abf6819c3bcc986e1d6b78f19b5a5681da96940e", version.Dist.Shasum)
+		assert.Contains(t, version.Dist.Integrity, "sha512-")
+		assert.Equal(t, server.URL+"/@acme/my-package/-/@acme/my-package-1.2.3.tgz", version.Dist.Tarball)
+
+		attachment := doc.Attachments["@acme/my-package-1.2.3.tgz"]
+		assert.Equal(t, base64.StdEncoding.EncodeToString(tarball), attachment.Data)
+		assert.Equal(t, len(tarball), attachment.Length)
+	}
+
+	doc.DistTags = nil
+	err = client.Publish(context.TODO(), manifest, tarball, "next", "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]string{"next": "1.2.3"}, doc.DistTags)
+	}
+
+	err = client.Publish(context.TODO(), []byte(`{"name": "my-package"}`), tarball, "", "")
+	assert.NotNil(t, err)
+}
+
+func TestPublishConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "cannot publish over the previously published versions"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+	err := client.Publish(context.TODO(), []byte(`{"name": "my-package", "version": "1.0.0"}`), []byte("tarball"), "", "")
+	if assert.NotNil(t, err) {
+		assert.True(t, errors.Is(err, ErrForbidden))
+		assert.Contains(t, err.Error(), "previously published")
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/-/whoami" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"username": "drone"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+	username, err := client.Whoami(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, "drone", username)
+
+	client, _ = New(server.URL, Auth{Token: "wrong"}, server.Client())
+	_, err = client.Whoami(context.TODO())
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+// Package registry provides a minimal client for the npm registry HTTP API.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type (
+	// Auth holds the credentials used to talk to the registry. A Token takes
+	// precedence over a Username and Password.
+	Auth struct {
+		Token    string
+		Username string
+		Password string
+	}
+
+	// Client talks to a single npm registry.
+	Client struct {
+		base   string
+		auth   Auth
+		client *http.Client
+	}
+
+	// Packument is the document the registry serves for a package name.
+	Packument struct {
+		Name     string               `json:"name"`
+		DistTags map[string]string    `json:"dist-tags"`
+		Versions map[string]Manifest  `json:"versions"`
+		Time     map[string]time.Time `json:"time"`
+
+		// unpublished is set when the registry serves the document of a
+		// package which was unpublished entirely.
+		unpublished bool
+	}
+
+	// Manifest is a single published version within a Packument.
+	Manifest struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		Deprecated string `json:"deprecated,omitempty"`
+		Dist       Dist   `json:"dist"`
+	}
+
+	// Dist describes the tarball of a published version.
+	Dist struct {
+		Shasum    string `json:"shasum"`
+		Integrity string `json:"integrity,omitempty"`
+		Tarball   string `json:"tarball"`
+	}
+)
+
+// New creates a Client for the registry at the given URL. When client is nil
+// the http.DefaultClient is used.
+func New(registry string, auth Auth, client *http.Client) (*Client, error) {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry url %s: %w", registry, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid registry url %s: scheme must be http or https", registry)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{
+		base:   strings.TrimSuffix(u.String(), "/"),
+		auth:   auth,
+		client: client,
+	}, nil
+}
+
+// Packument retrieves the packument for the named package. If the package has
+// never been published the returned error matches ErrNotFound.
+func (c *Client) Packument(ctx context.Context, name string) (*Packument, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PackagePath(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	packument := &Packument{}
+	if err := c.do(req, packument); err != nil {
+		return nil, err
+	}
+
+	// the registry keeps serving a document without versions for a package
+	// which was unpublished, which is the same as never being published
+	if packument.unpublished {
+		return nil, &StatusError{
+			Method:     req.Method,
+			URL:        req.URL.Redacted(),
+			StatusCode: http.StatusNotFound,
+			Message:    "package was unpublished",
+		}
+	}
+
+	return packument, nil
+}
+
+// UnmarshalJSON decodes the packument, keeping only the entries of the time
+// field which are times. The registry records an unpublished package as an
+// object in place of a time.
+func (p *Packument) UnmarshalJSON(data []byte) error {
+	type packument Packument
+	doc := struct {
+		*packument
+		Time map[string]json.RawMessage `json:"time"`
+	}{packument: (*packument)(p)}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	p.Time = map[string]time.Time{}
+	for key, value := range doc.Time {
+		var t time.Time
+		if err := json.Unmarshal(value, &t); err != nil {
+			if key == "unpublished" {
+				p.unpublished = true
+			}
+			continue
+		}
+		p.Time[key] = t
+	}
+
+	return nil
+}
+
+// PackagePath returns the path of a package relative to the registry root,
+// escaping the slash in scoped package names.
+func PackagePath(name string) string {
+	return "/" + url.PathEscape(name)
+}
+
+// newRequest creates an authenticated request for the path relative to the
+// registry root.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.auth.Token)
+	} else if c.auth.Username != "" {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+
+	return req, nil
+}
+
+// do sends the request and decodes a successful JSON response into v. Any non
+// 2xx response is turned into a *StatusError.
+func (c *Client) do(req *http.Request, v interface{}) error {
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return newStatusError(req, res)
+	}
+
+	if v == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: could not decode response: %w", req.Method, req.URL.Redacted(), err)
+	}
+
+	return nil
+}
+
+var (
+	// ErrNotFound is matched by errors for a 404 response.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is matched by errors for a 401 response.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is matched by errors for a 403 response.
+	ErrForbidden = errors.New("forbidden")
+	// ErrServer is matched by errors for a 5xx response.
+	ErrServer = errors.New("server error")
+)
+
+// StatusError is returned when the registry responds with a non 2xx status.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+// Is allows errors.Is to match a StatusError against the sentinel errors.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// maxErrorBody limits how much of an error response is read.
+const maxErrorBody = 64 * 1024
+
+func newStatusError(req *http.Request, res *http.Response) *StatusError {
+	e := &StatusError{
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		StatusCode: res.StatusCode,
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	// The registry usually reports errors as {"error": "..."} but some
+	// implementations use "message" or "reason" instead
+	var doc struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Reason  string `json:"reason"`
+	}
+	if json.Unmarshal(body, &doc) == nil {
+		switch {
+		case doc.Error != "":
+			e.Message = doc.Error
+		case doc.Message != "":
+			e.Message = doc.Message
+		case doc.Reason != "":
+			e.Message = doc.Reason
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackagePath(t *testing.T) {
+	assert.Equal(t, "/my-package", PackagePath("my-package"))
+	assert.Equal(t, "/@acme%2Fmy-package", PackagePath("@acme/my-package"))
+}
+
+func TestNewInvalidRegistry(t *testing.T) {
+	_, err := New("fakenpm.reg.org/good/path", Auth{}, nil)
+	assert.NotNil(t, err)
+
+	_, err = New("ftp://fakenpm.reg.org", Auth{}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestPackument(t *testing.T) {
+	var authorization, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		path = r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"name": "@acme/my-package",
+			"dist-tags": {"latest": "1.0.0"},
+			"versions": {"1.0.0": {"name": "@acme/my-package", "version": "1.0.0", "dist": {"shasum": "abc"}}},
+			"time": {"1.0.0": "2020-01-02T03:04:05.000Z"}
+		}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL+"/good/path/", Auth{Token: "token"}, server.Client())
+	if assert.Nil(t, err) {
+		packument, err := client.Packument(context.TODO(), "@acme/my-package")
+		if assert.Nil(t, err) {
+			assert.Equal(t, "1.0.0", packument.DistTags["latest"])
+			assert.Equal(t, "abc", packument.Versions["1.0.0"].Dist.Shasum)
+			assert.Equal(t, 2020, packument.Time["1.0.0"].Year())
+		}
+		assert.Equal(t, "Bearer token", authorization)
+		assert.Equal(t, "/good/path/@acme%2Fmy-package", path)
+	}
+
+	client, _ = New(server.URL, Auth{Username: "user", Password: "pass"}, server.Client())
+	_, err = client.Packument(context.TODO(), "my-package")
+	assert.Nil(t, err)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", authorization)
+}
+
+func TestPackumentUnpublished(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"name": "my-package",
+			"time": {
+				"created": "2020-01-02T03:04:05.000Z",
+				"modified": "2020-01-03T03:04:05.000Z",
+				"unpublished": {"time": "2020-01-03T03:04:05.000Z", "versions": ["1.0.0"]}
+			}
+		}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{}, server.Client())
+	_, err := client.Packument(context.TODO(), "my-package")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// entries which are not times are skipped
+	packument := &Packument{}
+	err = json.Unmarshal([]byte(`{"time": {"1.0.0": "2020-01-02T03:04:05.000Z", "other": 1}}`), packument)
+	if assert.Nil(t, err) {
+		assert.Len(t, packument.Time, 1)
+		assert.False(t, packument.unpublished)
+	}
+}
+
+func TestPackumentErrors(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error": "something went wrong"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{}, server.Client())
+
+	tests := []struct {
+		status int
+		target error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusBadGateway, ErrServer},
+	}
+
+	for _, test := range tests {
+		status = test.status
+		_, err := client.Packument(context.TODO(), "my-package")
+		if assert.NotNil(t, err) {
+			assert.True(t, errors.Is(err, test.target), "status %d should match %v", test.status, test.target)
+			assert.False(t, test.target != ErrNotFound && errors.Is(err, ErrNotFound))
+			assert.Contains(t, err.Error(), "something went wrong")
+
+			var statusErr *StatusError
+			if assert.True(t, errors.As(err, &statusErr)) {
+				assert.Equal(t, test.status, statusErr.StatusCode)
+			}
+		}
+	}
+}
+
+func TestPackumentNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	client, _ := New(server.URL, Auth{}, server.Client())
+	server.Close()
+
+	_, err := client.Packument(context.TODO(), "my-package")
+	if assert.NotNil(t, err) {
+		assert.False(t, errors.Is(err, ErrNotFound))
+	}
+}
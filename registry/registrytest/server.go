@@ -0,0 +1,676 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+// Package registrytest provides an in-process npm registry for tests.
+package registrytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Server is a fake npm registry keeping its packages in memory. It
+	// implements enough of the registry API to publish packages, query
+	// them, manage dist-tags, deprecate versions and authenticate with a
+	// token, a username and password or an OIDC ID token exchanged for a
+	// token.
+	Server struct {
+		*httptest.Server
+
+		// Private requires authentication to read packages.
+		Private bool
+
+		mu          sync.Mutex
+		packages    map[string]*Packument
+		unpublished map[string]json.RawMessage
+		tarballs    map[string][]byte
+		tokens      map[string]string
+		users       map[string]string
+		trusted     map[string]string
+		requests    []string
+	}
+
+	// Packument is a package stored in the Server.
+	Packument struct {
+		Name     string                     `json:"name"`
+		Rev      string                     `json:"_rev"`
+		DistTags map[string]string          `json:"dist-tags"`
+		Versions map[string]json.RawMessage `json:"versions"`
+		Time     map[string]string          `json:"time"`
+	}
+
+	// updateDocument is the body the npm CLI sends to update the versions of
+	// a package, such as deprecating them.
+	updateDocument struct {
+		Rev         string                     `json:"_rev"`
+		Versions    map[string]json.RawMessage `json:"versions"`
+		Attachments map[string]json.RawMessage `json:"_attachments"`
+	}
+
+	// publishDocument is the body the npm CLI sends to publish a version.
+	publishDocument struct {
+		Name        string                     `json:"name"`
+		DistTags    map[string]string          `json:"dist-tags"`
+		Versions    map[string]json.RawMessage `json:"versions"`
+		Attachments map[string]struct {
+			Data string `json:"data"`
+		} `json:"_attachments"`
+	}
+)
+
+// NewServer starts a Server without any packages or credentials.
+func NewServer() *Server {
+	s := &Server{
+		packages:    map[string]*Packument{},
+		unpublished: map[string]json.RawMessage{},
+		tarballs:    map[string][]byte{},
+		tokens:      map[string]string{},
+		users:       map[string]string{},
+		trusted:     map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// AddToken allows the token to authenticate as the user.
+func (s *Server) AddToken(token, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = username
+}
+
+// AddUser allows the user to authenticate with the password.
+func (s *Server) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[username] = password
+}
+
+// AddTrustedPublisher allows the OIDC ID token to be exchanged for a token
+// publishing the named package.
+func (s *Server) AddTrustedPublisher(idToken, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trusted[idToken] = name
+}
+
+// Packument returns a copy of the named package.
+func (s *Server) Packument(name string) (*Packument, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pkg, ok := s.packages[name]
+	if !ok {
+		return nil, false
+	}
+
+	data, _ := json.Marshal(pkg)
+	copied := &Packument{}
+	json.Unmarshal(data, copied) //nolint:errcheck
+
+	return copied, true
+}
+
+// Tarball returns the tarball uploaded for the version of the package.
+func (s *Server) Tarball(name, version string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.tarballs[tarballKey(name, version)]
+	return data, ok
+}
+
+// SetTime overrides the publish time of the version of the package.
+func (s *Server) SetTime(name, version string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pkg, ok := s.packages[name]; ok {
+		pkg.Time[version] = t.UTC().Format(time.RFC3339)
+	}
+}
+
+// Requests returns the method and path of every request received.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	switch {
+	case path == "/-/whoami":
+		s.handleWhoami(w, r)
+	case strings.HasPrefix(path, "/-/user/org.couchdb.user:"):
+		s.handleLogin(w, r, strings.TrimPrefix(path, "/-/user/org.couchdb.user:"))
+	case strings.HasPrefix(path, "/-/npm/v1/oidc/token/exchange/package/"):
+		name, rest := splitName(strings.TrimPrefix(path, "/-/npm/v1/oidc/token/exchange/package/"))
+		if rest != "" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		s.handleOIDCExchange(w, r, name)
+	case strings.HasPrefix(path, "/-/package/"):
+		name, rest := splitName(strings.TrimPrefix(path, "/-/package/"))
+		if rest != "/dist-tags" && !strings.HasPrefix(rest, "/dist-tags/") {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		s.handleDistTags(w, r, name, strings.TrimPrefix(strings.TrimPrefix(rest, "/dist-tags"), "/"))
+	default:
+		name, rest := splitName(strings.TrimPrefix(path, "/"))
+		switch {
+		case name == "":
+			writeError(w, http.StatusNotFound, "not found")
+		case rest == "":
+			s.handlePackage(w, r, name)
+		case strings.HasPrefix(rest, "/-rev/"):
+			s.handleRevision(w, r, name, strings.TrimPrefix(rest, "/-rev/"))
+		case strings.HasPrefix(rest, "/-/"):
+			s.handleTarball(w, r, name, strings.TrimPrefix(rest, "/-/"))
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	}
+}
+
+// splitName splits the package name off the start of the escaped path. Scoped
+// names are accepted with an escaped or a plain slash.
+func splitName(path string) (string, string) {
+	segments := strings.SplitN(path, "/", 3)
+	first, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return "", ""
+	}
+
+	rest := ""
+	if strings.HasPrefix(first, "@") && !strings.Contains(first, "/") {
+		if len(segments) < 2 {
+			return "", ""
+		}
+		first += "/" + segments[1]
+		if len(segments) == 3 {
+			rest = "/" + segments[2]
+		}
+	} else if len(segments) > 1 {
+		rest = "/" + strings.Join(segments[1:], "/")
+	}
+
+	return first, rest
+}
+
+// authenticate returns the user making the request or an empty string.
+func (s *Server) authenticate(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+
+	if token := strings.TrimPrefix(header, "Bearer "); token != header {
+		return s.tokens[token]
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		if expected, found := s.users[username]; found && expected == password {
+			return username
+		}
+	}
+
+	return ""
+}
+
+func (s *Server) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	username := s.authenticate(r)
+	if username == "" {
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"username": username})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request, username string) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body := struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if expected, found := s.users[username]; !found || expected != body.Password || body.Name != username {
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	token := fmt.Sprintf("npm_%s_%d", username, len(s.tokens)+1)
+	s.tokens[token] = username
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"ok":    true,
+		"id":    "org.couchdb.user:" + username,
+		"token": token,
+	})
+}
+
+func (s *Server) handleOIDCExchange(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	idToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if trusted, ok := s.trusted[idToken]; !ok || trusted != name {
+		writeError(w, http.StatusUnauthorized, "no trusted publisher matches the id token")
+		return
+	}
+
+	token := fmt.Sprintf("npm_oidc_%d", len(s.tokens)+1)
+	s.tokens[token] = "oidc"
+
+	writeJSON(w, http.StatusCreated, map[string]string{"token": token})
+}
+
+func (s *Server) handlePackage(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		if s.Private && s.authenticate(r) == "" {
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		pkg, ok := s.packages[name]
+		if tombstone, found := s.unpublished[name]; !ok && found {
+			writeJSON(w, http.StatusOK, tombstone)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, pkg)
+	case http.MethodPut:
+		if s.authenticate(r) == "" {
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// a document without attachments updates the existing versions
+		doc := updateDocument{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(doc.Attachments) == 0 {
+			s.handleUpdate(w, name, &doc)
+			return
+		}
+
+		s.handlePublish(w, body, name)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, name string, doc *updateDocument) {
+	pkg, ok := s.packages[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if doc.Rev != pkg.Rev {
+		writeError(w, http.StatusConflict, "document update conflict")
+		return
+	}
+	for version := range doc.Versions {
+		if _, found := pkg.Versions[version]; !found {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("version %s not found", version))
+			return
+		}
+	}
+
+	for version, manifest := range doc.Versions {
+		pkg.Versions[version] = manifest
+	}
+	pkg.Time["modified"] = time.Now().UTC().Format(time.RFC3339)
+
+	pkg.Rev = nextRev(pkg.Rev, len(pkg.Versions))
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (s *Server) handlePublish(w http.ResponseWriter, body []byte, name string) {
+	doc := publishDocument{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if doc.Name != name || len(doc.Versions) != 1 || len(doc.Attachments) != 1 {
+		writeError(w, http.StatusBadRequest, "invalid publish document")
+		return
+	}
+
+	pkg, ok := s.packages[name]
+	if !ok {
+		pkg = &Packument{
+			Name:     name,
+			DistTags: map[string]string{},
+			Versions: map[string]json.RawMessage{},
+			Time:     map[string]string{},
+		}
+	}
+
+	var version string
+	var manifest json.RawMessage
+	for v, m := range doc.Versions {
+		version, manifest = v, m
+	}
+	if _, found := pkg.Versions[version]; found {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("cannot publish over the previously published versions: %s", version))
+		return
+	}
+
+	var tarball []byte
+	for _, attachment := range doc.Attachments {
+		data, err := base64.StdEncoding.DecodeString(attachment.Data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		tarball = data
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, found := pkg.Time["created"]; !found {
+		pkg.Time["created"] = now
+	}
+	pkg.Time["modified"] = now
+	pkg.Time[version] = now
+	pkg.Versions[version] = manifest
+	if rewritten, err := s.rewriteTarballURL(manifest, name, version); err == nil {
+		pkg.Versions[version] = rewritten
+	}
+	for tag, tagged := range doc.DistTags {
+		pkg.DistTags[tag] = tagged
+	}
+	pkg.Rev = fmt.Sprintf("%d-%x", len(pkg.Versions), len(tarball))
+
+	s.packages[name] = pkg
+	delete(s.unpublished, name)
+	s.tarballs[tarballKey(name, version)] = tarball
+
+	writeJSON(w, http.StatusCreated, map[string]bool{"success": true})
+}
+
+func (s *Server) handleTarball(w http.ResponseWriter, r *http.Request, name, file string) {
+	if r.Method == http.MethodDelete {
+		s.handleTarballDelete(w, r, name, file)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	file, err := url.PathUnescape(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if data, ok := s.tarballs[name+"/-/"+file]; ok {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data) //nolint:errcheck
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "not found")
+}
+
+// handleRevision unpublishes a version by updating the document without it,
+// or the whole package when deleting the document.
+func (s *Server) handleRevision(w http.ResponseWriter, r *http.Request, name, rev string) {
+	if s.authenticate(r) == "" {
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	pkg, ok := s.packages[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if rev, err := url.PathUnescape(rev); err != nil || rev != pkg.Rev {
+		writeError(w, http.StatusConflict, "document update conflict")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		s.unpublished[name] = tombstone(pkg)
+		delete(s.packages, name)
+		for key := range s.tarballs {
+			if strings.HasPrefix(key, name+"/-/") {
+				delete(s.tarballs, key)
+			}
+		}
+	case http.MethodPut:
+		doc := publishDocument{}
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(doc.Versions) == 0 {
+			writeError(w, http.StatusBadRequest, "cannot remove every version")
+			return
+		}
+		for version := range doc.Versions {
+			if _, found := pkg.Versions[version]; !found {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("version %s not found", version))
+				return
+			}
+		}
+
+		pkg.Versions = doc.Versions
+		pkg.DistTags = doc.DistTags
+		pkg.Time["modified"] = time.Now().UTC().Format(time.RFC3339)
+		pkg.Rev = nextRev(pkg.Rev, len(pkg.Versions))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (s *Server) handleTarballDelete(w http.ResponseWriter, r *http.Request, name, file string) {
+	if s.authenticate(r) == "" {
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	pkg, ok := s.packages[name]
+	i := strings.Index(file, "/-rev/")
+	if !ok || i < 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if rev, err := url.PathUnescape(file[i+len("/-rev/"):]); err != nil || rev != pkg.Rev {
+		writeError(w, http.StatusConflict, "document update conflict")
+		return
+	}
+
+	file, err := url.PathUnescape(file[:i])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, found := s.tarballs[name+"/-/"+file]; !found {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	delete(s.tarballs, name+"/-/"+file)
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+func (s *Server) handleDistTags(w http.ResponseWriter, r *http.Request, name, tag string) {
+	pkg, ok := s.packages[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method == http.MethodGet && tag == "" {
+		writeJSON(w, http.StatusOK, pkg.DistTags)
+		return
+	}
+
+	if s.authenticate(r) == "" {
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+	if tag == "" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		var version string
+		if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, found := pkg.Versions[version]; !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("version %s not found", version))
+			return
+		}
+
+		pkg.DistTags[tag] = version
+	case http.MethodDelete:
+		if _, found := pkg.DistTags[tag]; !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("tag %s not found", tag))
+			return
+		}
+		if tag == "latest" {
+			writeError(w, http.StatusBadRequest, "the latest tag cannot be removed")
+			return
+		}
+
+		delete(pkg.DistTags, tag)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pkg.DistTags)
+}
+
+// rewriteTarballURL points the dist of the manifest to the tarball served by
+// the Server, as the registry does on publishing.
+func (s *Server) rewriteTarballURL(manifest json.RawMessage, name, version string) (json.RawMessage, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(manifest, &fields); err != nil {
+		return nil, err
+	}
+
+	dist, _ := fields["dist"].(map[string]interface{})
+	if dist == nil {
+		dist = map[string]interface{}{}
+	}
+	dist["tarball"] = s.URL + "/" + tarballKey(name, version)
+	fields["dist"] = dist
+
+	return json.Marshal(fields)
+}
+
+// tombstone creates the document served for a package which was unpublished
+// entirely, recording the unpublish as an object within the times.
+func tombstone(pkg *Packument) json.RawMessage {
+	versions := make([]string, 0, len(pkg.Versions))
+	for version := range pkg.Versions {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	data, _ := json.Marshal(map[string]interface{}{
+		"name": pkg.Name,
+		"time": map[string]interface{}{
+			"created":  pkg.Time["created"],
+			"modified": now,
+			"unpublished": map[string]interface{}{
+				"time":     now,
+				"versions": versions,
+			},
+		},
+	})
+
+	return data
+}
+
+// nextRev returns the revision following rev.
+func nextRev(rev string, versions int) string {
+	var n int
+	fmt.Sscanf(rev, "%d-", &n) //nolint:errcheck
+	return fmt.Sprintf("%d-%x", n+1, versions)
+}
+
+// tarballKey identifies the tarball of a version by its path below the
+// registry, which uses the name without the scope for the file.
+func tarballKey(name, version string) string {
+	return fmt.Sprintf("%s/-/%s-%s.tgz", name, packageBase(name), version)
+}
+
+// packageBase returns the package name without its scope.
+func packageBase(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registrytest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/drone-plugins/drone-npm/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func publish(t *testing.T, server *Server, name, version, tag string) {
+	t.Helper()
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	manifest := []byte(`{"name": "` + name + `", "version": "` + version + `"}`)
+	if err := client.Publish(context.TODO(), manifest, []byte(name+"@"+version), tag, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func request(t *testing.T, server *Server, method, path, body string, auth func(*http.Request)) (int, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if auth != nil {
+		auth(req)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func bearer(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer token")
+}
+
+func TestPublishAndPackument(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	publish(t, server, "@acme/my-package", "1.0.0", "")
+	publish(t, server, "@acme/my-package", "1.1.0-beta.1", "beta")
+
+	client, _ := registry.New(server.URL, registry.Auth{}, server.Client())
+	pkg, err := client.Packument(context.TODO(), "@acme/my-package")
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]string{"latest": "1.0.0", "beta": "1.1.0-beta.1"}, pkg.DistTags)
+		assert.Len(t, pkg.Versions, 2)
+		assert.Contains(t, pkg.Time, "1.1.0-beta.1")
+
+		tarball := pkg.Versions["1.0.0"].Dist.Tarball
+		assert.Equal(t, server.URL+"/@acme/my-package/-/my-package-1.0.0.tgz", tarball)
+
+		status, body := request(t, server, http.MethodGet, strings.TrimPrefix(tarball, server.URL), "", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "@acme/my-package@1.0.0", body)
+	}
+
+	data, ok := server.Tarball("@acme/my-package", "1.1.0-beta.1")
+	assert.True(t, ok)
+	assert.Equal(t, "@acme/my-package@1.1.0-beta.1", string(data))
+
+	_, err = client.Packument(context.TODO(), "missing")
+	assert.True(t, errors.Is(err, registry.ErrNotFound))
+}
+
+func TestPublishConflict(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	publish(t, server, "my-package", "1.0.0", "")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	err := client.Publish(context.TODO(), []byte(`{"name": "my-package", "version": "1.0.0"}`), []byte("again"), "", "")
+	assert.True(t, errors.Is(err, registry.ErrForbidden))
+
+	data, _ := server.Tarball("my-package", "1.0.0")
+	assert.Equal(t, "my-package@1.0.0", string(data))
+}
+
+func TestAuthentication(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	server.AddUser("hubot", "secret")
+
+	for _, test := range []struct {
+		name     string
+		auth     registry.Auth
+		username string
+		err      error
+	}{
+		{name: "token", auth: registry.Auth{Token: "token"}, username: "octocat"},
+		{name: "password", auth: registry.Auth{Username: "hubot", Password: "secret"}, username: "hubot"},
+		{name: "wrong token", auth: registry.Auth{Token: "wrong"}, err: registry.ErrUnauthorized},
+		{name: "wrong password", auth: registry.Auth{Username: "hubot", Password: "wrong"}, err: registry.ErrUnauthorized},
+		{name: "anonymous", err: registry.ErrUnauthorized},
+	} {
+		client, _ := registry.New(server.URL, test.auth, server.Client())
+		username, err := client.Whoami(context.TODO())
+		assert.Equal(t, test.username, username, test.name)
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), test.name)
+		} else {
+			assert.Nil(t, err, test.name)
+		}
+
+		err = client.Publish(context.TODO(), []byte(`{"name": "`+test.name+`", "version": "1.0.0"}`), []byte("data"), "", "")
+		if test.err != nil {
+			assert.True(t, errors.Is(err, test.err), test.name)
+		} else {
+			assert.Nil(t, err, test.name)
+		}
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	server.Private = true
+
+	publish(t, server, "my-package", "1.0.0", "")
+
+	anonymous, _ := registry.New(server.URL, registry.Auth{}, server.Client())
+	_, err := anonymous.Packument(context.TODO(), "my-package")
+	assert.True(t, errors.Is(err, registry.ErrUnauthorized))
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	_, err = client.Packument(context.TODO(), "my-package")
+	assert.Nil(t, err)
+}
+
+func TestLogin(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddUser("hubot", "secret")
+
+	status, _ := request(t, server, http.MethodPut, "/-/user/org.couchdb.user:hubot", `{"name": "hubot", "password": "wrong"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := request(t, server, http.MethodPut, "/-/user/org.couchdb.user:hubot", `{"name": "hubot", "password": "secret"}`, nil)
+	if assert.Equal(t, http.StatusCreated, status) {
+		assert.Contains(t, body, `"token":"npm_hubot_1"`)
+
+		client, _ := registry.New(server.URL, registry.Auth{Token: "npm_hubot_1"}, server.Client())
+		username, err := client.Whoami(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, "hubot", username)
+	}
+}
+
+func TestDistTags(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+
+	publish(t, server, "@acme/my-package", "1.0.0", "")
+	publish(t, server, "@acme/my-package", "2.0.0-rc.1", "next")
+
+	status, body := request(t, server, http.MethodGet, "/-/package/@acme%2Fmy-package/dist-tags", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"latest": "1.0.0", "next": "2.0.0-rc.1"}`, body)
+
+	status, _ = request(t, server, http.MethodPut, "/-/package/@acme%2Fmy-package/dist-tags/beta", `"2.0.0-rc.1"`, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = request(t, server, http.MethodPut, "/-/package/@acme%2Fmy-package/dist-tags/beta", `"3.0.0"`, bearer)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = request(t, server, http.MethodPut, "/-/package/@acme/my-package/dist-tags/beta", `"2.0.0-rc.1"`, bearer)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"latest": "1.0.0", "next": "2.0.0-rc.1", "beta": "2.0.0-rc.1"}`, body)
+
+	status, _ = request(t, server, http.MethodDelete, "/-/package/@acme%2Fmy-package/dist-tags/latest", "", bearer)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = request(t, server, http.MethodDelete, "/-/package/@acme%2Fmy-package/dist-tags/next", "", bearer)
+	assert.Equal(t, http.StatusOK, status)
+
+	pkg, _ := server.Packument("@acme/my-package")
+	assert.Equal(t, map[string]string{"latest": "1.0.0", "beta": "2.0.0-rc.1"}, pkg.DistTags)
+}
+
+func TestOIDCExchange(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddTrustedPublisher("id-token", "@acme/my-package")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "id-token"}, server.Client())
+	_, err := client.ExchangeOIDCToken(context.TODO(), "other-package")
+	assert.True(t, errors.Is(err, registry.ErrUnauthorized))
+
+	token, err := client.ExchangeOIDCToken(context.TODO(), "@acme/my-package")
+	if assert.Nil(t, err) {
+		client, _ = registry.New(server.URL, registry.Auth{Token: token}, server.Client())
+		username, err := client.Whoami(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, "oidc", username)
+	}
+}
+
+func TestDeprecate(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	publish(t, server, "@acme/my-package", "1.0.0", "")
+	publish(t, server, "@acme/my-package", "1.1.0", "")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	err := client.Deprecate(context.TODO(), "@acme/my-package", map[string]string{"1.0.0": "vulnerable"})
+	if assert.Nil(t, err) {
+		pkg, _ := server.Packument("@acme/my-package")
+		assert.Contains(t, string(pkg.Versions["1.0.0"]), `"deprecated":"vulnerable"`)
+		assert.NotContains(t, string(pkg.Versions["1.1.0"]), "deprecated")
+	}
+
+	err = client.Deprecate(context.TODO(), "@acme/my-package", map[string]string{"1.0.0": ""})
+	if assert.Nil(t, err) {
+		pkg, _ := server.Packument("@acme/my-package")
+		assert.NotContains(t, string(pkg.Versions["1.0.0"]), "deprecated")
+	}
+
+	// an outdated revision is a conflict
+	status, _ := request(t, server, http.MethodPut, "/@acme%2Fmy-package", `{"_rev": "1-0", "versions": {}}`, func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer token")
+	})
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestUnpublish(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddToken("token", "octocat")
+	publish(t, server, "@acme/my-package", "1.0.0", "")
+	publish(t, server, "@acme/my-package", "1.1.0", "")
+	publish(t, server, "@acme/my-package", "2.0.0-rc.1", "next")
+
+	client, _ := registry.New(server.URL, registry.Auth{Token: "token"}, server.Client())
+	if assert.Nil(t, client.Unpublish(context.TODO(), "@acme/my-package", "1.1.0")) {
+		pkg, _ := server.Packument("@acme/my-package")
+		assert.NotContains(t, pkg.Versions, "1.1.0")
+		assert.Equal(t, map[string]string{"latest": "2.0.0-rc.1", "next": "2.0.0-rc.1"}, pkg.DistTags)
+
+		_, ok := server.Tarball("@acme/my-package", "1.1.0")
+		assert.False(t, ok)
+		_, ok = server.Tarball("@acme/my-package", "1.0.0")
+		assert.True(t, ok)
+	}
+
+	err := client.Unpublish(context.TODO(), "@acme/my-package", "1.1.0")
+	assert.NotNil(t, err)
+
+	if assert.Nil(t, client.UnpublishPackage(context.TODO(), "@acme/my-package")) {
+		_, ok := server.Packument("@acme/my-package")
+		assert.False(t, ok)
+
+		// the registry serves a tombstone for the unpublished package
+		status, body := request(t, server, http.MethodGet, "/@acme%2Fmy-package", "", nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `"unpublished"`)
+
+		_, err := client.Packument(context.TODO(), "@acme/my-package")
+		assert.True(t, errors.Is(err, registry.ErrNotFound))
+	}
+
+	// an outdated revision is a conflict
+	publish(t, server, "my-package", "1.0.0", "")
+	status, _ := request(t, server, http.MethodDelete, "/my-package/-rev/0-0", "", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer token")
+	})
+	assert.Equal(t, http.StatusConflict, status)
+}
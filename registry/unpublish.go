@@ -0,0 +1,171 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/drone-plugins/drone-npm/semver"
+)
+
+// writeDocument is the packument as stored by the registry, as far as
+// unpublishing requires it.
+type writeDocument struct {
+	Rev      string                     `json:"_rev"`
+	DistTags map[string]string          `json:"dist-tags"`
+	Versions map[string]json.RawMessage `json:"versions"`
+}
+
+// Unpublish removes the version of the package, which must not be its only
+// version. Dist-tags pointing at the version are removed, except for latest
+// which moves to the highest remaining version as the npm CLI does.
+func (c *Client) Unpublish(ctx context.Context, name, version string) error {
+	doc, raw, err := c.writeDocument(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	manifest, found := doc.Versions[version]
+	if !found {
+		return fmt.Errorf("version %s of %s is not published", version, name)
+	}
+	if len(doc.Versions) == 1 {
+		return fmt.Errorf("version %s is the only version of %s", version, name)
+	}
+
+	delete(doc.Versions, version)
+	for tag, tagged := range doc.DistTags {
+		if tagged == version {
+			delete(doc.DistTags, tag)
+		}
+	}
+	if _, found := doc.DistTags["latest"]; !found {
+		doc.DistTags["latest"] = highestVersion(doc.Versions)
+	}
+
+	if raw["versions"], err = json.Marshal(doc.Versions); err != nil {
+		return err
+	}
+	if raw["dist-tags"], err = json.Marshal(doc.DistTags); err != nil {
+		return err
+	}
+	body, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPut, revPath(PackagePath(name), doc.Rev), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("npm-command", "unpublish")
+	if err := c.do(req, nil); err != nil {
+		return err
+	}
+
+	// the tarball is removed at the revision of the updated document
+	doc, _, err = c.writeDocument(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	req, err = c.newRequest(ctx, http.MethodDelete, revPath(c.tarballPath(name, version, manifest), doc.Rev), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("npm-command", "unpublish")
+
+	return c.do(req, nil)
+}
+
+// UnpublishPackage removes the package with every version.
+func (c *Client) UnpublishPackage(ctx context.Context, name string) error {
+	doc, _, err := c.writeDocument(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodDelete, revPath(PackagePath(name), doc.Rev), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("npm-command", "unpublish")
+
+	return c.do(req, nil)
+}
+
+// writeDocument retrieves the packument as stored by the registry, both
+// decoded and raw so fields this client doesn't know about are sent back
+// unchanged.
+func (c *Client) writeDocument(ctx context.Context, name string) (*writeDocument, map[string]json.RawMessage, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, PackagePath(name)+"?write=true", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	raw := map[string]json.RawMessage{}
+	if err := c.do(req, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	doc := &writeDocument{}
+	body, _ := json.Marshal(raw)
+	if err := json.Unmarshal(body, doc); err != nil {
+		return nil, nil, fmt.Errorf("%s %s: could not decode response: %w", req.Method, req.URL.Redacted(), err)
+	}
+	if doc.DistTags == nil {
+		doc.DistTags = map[string]string{}
+	}
+
+	return doc, raw, nil
+}
+
+// tarballPath returns the path of the tarball of the version relative to the
+// registry root, falling back to where the registry stores tarballs by
+// default when the manifest points elsewhere.
+func (c *Client) tarballPath(name, version string, manifest json.RawMessage) string {
+	m := Manifest{}
+	if json.Unmarshal(manifest, &m) == nil && strings.HasPrefix(m.Dist.Tarball, c.base+"/") {
+		return strings.TrimPrefix(m.Dist.Tarball, c.base)
+	}
+
+	base := name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		base = name[i+1:]
+	}
+
+	return PackagePath(name) + "/-/" + url.PathEscape(base+"-"+version+".tgz")
+}
+
+// revPath appends the revision of the document to the path.
+func revPath(path, rev string) string {
+	return path + "/-rev/" + url.PathEscape(rev)
+}
+
+// highestVersion returns the version with the highest precedence.
+func highestVersion(versions map[string]json.RawMessage) string {
+	var highest *semver.Version
+	var highestRaw string
+	for raw := range versions {
+		version, err := semver.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if highest == nil || semver.Compare(version, highest) > 0 {
+			highest, highestRaw = version, raw
+		}
+	}
+
+	return highestRaw
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnpublish(t *testing.T) {
+	var requests []string
+	var doc writeDocument
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"_rev": "3-abc", "dist-tags": {"latest": "1.1.0", "beta": "1.1.0"}, "versions": {` + //nolint:errcheck
+				`"1.0.0": {}, "1.1.0": {"dist": {"tarball": "https://cdn.acme.com/my-package-1.1.0.tgz"}}, "1.2.0-rc.1": {}}}`))
+		case http.MethodPut:
+			json.NewDecoder(r.Body).Decode(&doc) //nolint:errcheck
+			w.Write([]byte(`{}`))                //nolint:errcheck
+		default:
+			w.Write([]byte(`{}`)) //nolint:errcheck
+		}
+	}))
+	defer server.Close()
+
+	client, _ := New(server.URL, Auth{Token: "token"}, server.Client())
+	err := client.Unpublish(context.TODO(), "@acme/my-package", "1.1.0")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{
+			"GET /@acme%2Fmy-package?write=true",
+			"PUT /@acme%2Fmy-package/-rev/3-abc",
+			"GET /@acme%2Fmy-package?write=true",
+			"DELETE /@acme%2Fmy-package/-/my-package-1.1.0.tgz/-rev/3-abc",
+		}, requests)
+		assert.NotContains(t, doc.Versions, "1.1.0")
+		assert.Equal(t, map[string]string{"latest": "1.2.0-rc.1"}, doc.DistTags)
+	}
+
+	err = client.Unpublish(context.TODO(), "@acme/my-package", "2.0.0")
+	assert.NotNil(t, err)
+}
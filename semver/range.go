@@ -0,0 +1,328 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package semver
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Range is a set of versions described with the range syntax of npm, such
+	// as "^1.2.0 || >=2.0.0-beta <2.0.0". A version is in the range when it
+	// satisfies every comparator of one of the sets.
+	Range struct {
+		sets              [][]comparator
+		includePrerelease bool
+	}
+
+	// comparator compares a version against the version of the comparator.
+	comparator struct {
+		op      string
+		version *Version
+	}
+
+	// partial is a version of a range which may leave out the minor and patch
+	// or replace them with a wildcard.
+	partial struct {
+		major, minor, patch uint64
+		parts               int
+		prerelease          []string
+		includePrerelease   bool
+	}
+)
+
+var (
+	// partialRegexp matches the versions of a range, allowing wildcards and a
+	// leading "v".
+	partialRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*|[xX*])(?:\.(0|[1-9]\d*|[xX*])(?:\.(0|[1-9]\d*|[xX*])` +
+		`(?:-([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?(?:\+[0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*)?)?)?$`)
+
+	// operatorSpaceRegexp matches the whitespace between an operator and its
+	// version.
+	operatorSpaceRegexp = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+
+	// none is a comparator no version satisfies.
+	none = comparator{op: "<", version: &Version{Prerelease: []string{"0"}}}
+)
+
+// ParseRange parses a range. An empty range or "*" contains every version
+// which is not a prerelease.
+func ParseRange(r string) (*Range, error) {
+	return parseRange(r, false)
+}
+
+// ParseRangeIncludePrerelease parses a range which contains prerelease
+// versions like any other version, as npm does with the includePrerelease
+// option. A partial version includes the prereleases of its lowest version,
+// so "1.x" contains 1.0.0-beta and an empty range contains every version.
+func ParseRangeIncludePrerelease(r string) (*Range, error) {
+	return parseRange(r, true)
+}
+
+func parseRange(r string, includePrerelease bool) (*Range, error) {
+	parsed := &Range{includePrerelease: includePrerelease}
+
+	for _, set := range strings.Split(r, "||") {
+		comparators, err := parseSet(strings.TrimSpace(set), includePrerelease)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid range: %w", r, err)
+		}
+		parsed.sets = append(parsed.sets, comparators)
+	}
+
+	return parsed, nil
+}
+
+// Contains reports whether the version is in the range. Unless the range
+// includes prereleases, prerelease versions are only contained when a
+// comparator of the matching set has a prerelease of the same major, minor
+// and patch, as npm does.
+func (r *Range) Contains(v *Version) bool {
+	for _, set := range r.sets {
+		if containsSet(set, v, r.includePrerelease) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsSet(set []comparator, v *Version, includePrerelease bool) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+
+	if includePrerelease || !v.IsPrerelease() {
+		return true
+	}
+
+	for _, c := range set {
+		if c.version.IsPrerelease() &&
+			c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c comparator) matches(v *Version) bool {
+	cmp := Compare(v, c.version)
+
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return cmp == 0
+}
+
+// parseSet parses the comparators of a set, which are separated by
+// whitespace or are a hyphen range.
+func parseSet(set string, includePrerelease bool) ([]comparator, error) {
+	if parts := strings.Split(set, " - "); len(parts) == 2 {
+		return parseHyphen(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), includePrerelease)
+	}
+
+	var comparators []comparator
+	for _, token := range strings.Fields(operatorSpaceRegexp.ReplaceAllString(set, "$1")) {
+		parsed, err := parseComparator(token, includePrerelease)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, parsed...)
+	}
+
+	return comparators, nil
+}
+
+// parseHyphen parses a hyphen range, where a partial upper version includes
+// every version it matches.
+func parseHyphen(from, to string, includePrerelease bool) ([]comparator, error) {
+	lower, err := parsePartial(from, includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := parsePartial(to, includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+
+	var comparators []comparator
+	if lower.parts > 0 {
+		comparators = append(comparators, comparator{op: ">=", version: lower.floor()})
+	}
+
+	switch upper.parts {
+	case 0:
+	case 3:
+		comparators = append(comparators, comparator{op: "<=", version: upper.floor()})
+	default:
+		comparators = append(comparators, comparator{op: "<", version: upper.next()})
+	}
+
+	return comparators, nil
+}
+
+// parseComparator desugars an operator and its partial version into plain
+// comparators.
+func parseComparator(token string, includePrerelease bool) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{"<=", ">=", "~>", "<", ">", "=", "~", "^"} {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	p, err := parsePartial(strings.TrimPrefix(token, op), includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 3 {
+			return []comparator{{op: "=", version: p.floor()}}, nil
+		}
+		return p.within(p.next()), nil
+	case "~", "~>":
+		if p.parts == 3 {
+			return p.within(&Version{Major: p.major, Minor: p.minor + 1, Prerelease: []string{"0"}}), nil
+		}
+		return p.within(p.next()), nil
+	case "^":
+		return p.within(p.caretUpper()), nil
+	case ">":
+		switch p.parts {
+		case 0:
+			return []comparator{none}, nil
+		case 3:
+			return []comparator{{op: ">", version: p.floor()}}, nil
+		}
+		// npm only starts at the first prerelease when prereleases are
+		// included, otherwise it would let in the prereleases of next
+		next := p.next()
+		if !p.includePrerelease {
+			next.Prerelease = nil
+		}
+		return []comparator{{op: ">=", version: next}}, nil
+	case ">=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		return []comparator{{op: ">=", version: p.floor()}}, nil
+	case "<":
+		if p.parts == 0 {
+			return []comparator{none}, nil
+		}
+		floor := p.floor()
+		if p.parts < 3 {
+			floor.Prerelease = []string{"0"}
+		}
+		return []comparator{{op: "<", version: floor}}, nil
+	case "<=":
+		switch p.parts {
+		case 0:
+			return nil, nil
+		case 3:
+			return []comparator{{op: "<=", version: p.floor()}}, nil
+		}
+		return []comparator{{op: "<", version: p.next()}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %s", op)
+}
+
+// parsePartial parses a version which may be partial.
+func parsePartial(version string, includePrerelease bool) (*partial, error) {
+	match := partialRegexp.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a valid version", version)
+	}
+
+	p := &partial{includePrerelease: includePrerelease}
+	for i, field := range []*uint64{&p.major, &p.minor, &p.patch} {
+		value := match[i+1]
+		if value == "" || value == "x" || value == "X" || value == "*" {
+			break
+		}
+
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || n == math.MaxUint64 {
+			return nil, fmt.Errorf("%q is not a valid version", version)
+		}
+		*field = n
+		p.parts++
+	}
+
+	if match[4] != "" && p.parts == 3 {
+		p.prerelease = strings.Split(match[4], ".")
+	}
+
+	return p, nil
+}
+
+// floor returns the lowest version the partial matches, which is the first
+// prerelease of a partial version when prereleases are included.
+func (p *partial) floor() *Version {
+	prerelease := p.prerelease
+	if p.includePrerelease && p.parts < 3 {
+		prerelease = []string{"0"}
+	}
+
+	return &Version{Major: p.major, Minor: p.minor, Patch: p.patch, Prerelease: prerelease}
+}
+
+// next returns the lowest version above the ones the partial matches.
+func (p *partial) next() *Version {
+	switch p.parts {
+	case 1:
+		return &Version{Major: p.major + 1, Prerelease: []string{"0"}}
+	case 2:
+		return &Version{Major: p.major, Minor: p.minor + 1, Prerelease: []string{"0"}}
+	}
+
+	return &Version{Major: p.major, Minor: p.minor, Patch: p.patch + 1, Prerelease: []string{"0"}}
+}
+
+// caretUpper returns the lowest version above the ones a caret range allows,
+// which keep the first non-zero part of the partial.
+func (p *partial) caretUpper() *Version {
+	switch {
+	case p.parts == 1 || p.major > 0:
+		return &Version{Major: p.major + 1, Prerelease: []string{"0"}}
+	case p.parts == 2 || p.minor > 0:
+		return &Version{Minor: p.minor + 1, Prerelease: []string{"0"}}
+	}
+
+	return &Version{Patch: p.patch + 1, Prerelease: []string{"0"}}
+}
+
+// within returns the comparators for the versions from the floor of the
+// partial up to the upper version. A wildcard matches every version.
+func (p *partial) within(upper *Version) []comparator {
+	if p.parts == 0 {
+		return nil
+	}
+
+	return []comparator{
+		{op: ">=", version: p.floor()},
+		{op: "<", version: upper},
+	}
+}
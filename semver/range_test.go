@@ -0,0 +1,98 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeContains(t *testing.T) {
+	for _, test := range []struct {
+		r        string
+		contains []string
+		excludes []string
+	}{
+		{r: "", contains: []string{"0.0.0", "1.2.3"}, excludes: []string{"1.2.3-beta"}},
+		{r: "*", contains: []string{"1.2.3"}},
+		{r: "1.2.3", contains: []string{"1.2.3"}, excludes: []string{"1.2.4", "1.2.3-beta"}},
+		{r: "=v1.2.3", contains: []string{"1.2.3"}},
+		{r: "1.x", contains: []string{"1.0.0", "1.9.9"}, excludes: []string{"2.0.0", "0.9.9"}},
+		{r: "1.2", contains: []string{"1.2.0", "1.2.9"}, excludes: []string{"1.3.0"}},
+		{r: "~1.2.3", contains: []string{"1.2.3", "1.2.9"}, excludes: []string{"1.3.0", "1.2.2"}},
+		{r: "~1", contains: []string{"1.9.0"}, excludes: []string{"2.0.0"}},
+		{r: "^1.2.3", contains: []string{"1.2.3", "1.9.0"}, excludes: []string{"2.0.0", "1.2.2"}},
+		{r: "^0.2.3", contains: []string{"0.2.9"}, excludes: []string{"0.3.0"}},
+		{r: "^0.0.3", contains: []string{"0.0.3"}, excludes: []string{"0.0.4"}},
+		{r: "^0.x", contains: []string{"0.9.0"}, excludes: []string{"1.0.0"}},
+		{r: ">1.2", contains: []string{"1.3.0"}, excludes: []string{"1.2.9", "1.3.0-beta"}},
+		{r: ">= 1.2.3 < 1.4", contains: []string{"1.2.3", "1.3.9"}, excludes: []string{"1.4.0", "1.2.2"}},
+		{r: "<=1.2", contains: []string{"1.2.9"}, excludes: []string{"1.3.0", "1.3.0-beta"}},
+		{r: "<1", contains: []string{"0.9.9"}, excludes: []string{"1.0.0", "1.0.0-beta"}},
+		{r: "1.2 - 2.3.4", contains: []string{"1.2.0", "2.3.4"}, excludes: []string{"2.3.5", "1.1.9"}},
+		{r: "1.2.3 - 2", contains: []string{"2.9.9"}, excludes: []string{"3.0.0"}},
+		{r: "<1.0.0 || >=2.0.0", contains: []string{"0.5.0", "2.1.0"}, excludes: []string{"1.5.0"}},
+		{r: ">=1.2.3-beta.2 <1.3.0", contains: []string{"1.2.3-beta.2", "1.2.3-rc.1", "1.2.5"}, excludes: []string{"1.2.4-beta.1", "1.2.3-beta.1"}},
+	} {
+		r, err := ParseRange(test.r)
+		if !assert.Nil(t, err, "%q should parse", test.r) {
+			continue
+		}
+
+		for _, version := range test.contains {
+			assert.True(t, r.Contains(mustParse(t, version)), "%q should contain %s", test.r, version)
+		}
+		for _, version := range test.excludes {
+			assert.False(t, r.Contains(mustParse(t, version)), "%q should exclude %s", test.r, version)
+		}
+	}
+
+	for _, invalid := range []string{"latest", "1.2.3.4", ">>1", "^v", "1.2 - "} {
+		_, err := ParseRange(invalid)
+		assert.NotNil(t, err, "%q should not parse", invalid)
+	}
+}
+
+func TestRangeContainsIncludePrerelease(t *testing.T) {
+	for _, test := range []struct {
+		r        string
+		contains []string
+		excludes []string
+	}{
+		{r: "", contains: []string{"0.0.0-alpha", "1.2.3-beta"}},
+		{r: "1.x", contains: []string{"1.0.0-beta", "1.5.0-rc.1"}, excludes: []string{"2.0.0-alpha", "0.9.9"}},
+		{r: "^1.2.3", contains: []string{"1.3.0-beta", "1.2.4-rc.1"}, excludes: []string{"1.2.3-beta", "2.0.0-beta"}},
+		{r: "~1.2", contains: []string{"1.2.0-beta", "1.2.5-rc.1"}, excludes: []string{"1.3.0-alpha"}},
+		{r: ">1.2", contains: []string{"1.3.0-beta", "1.3.0"}, excludes: []string{"1.2.9-rc.1"}},
+		{r: "<=1.2", contains: []string{"1.2.9-rc.1"}, excludes: []string{"1.3.0-beta"}},
+		{r: "<2.0.0", contains: []string{"1.9.0-beta", "2.0.0-beta"}, excludes: []string{"2.0.0"}},
+		{r: "1.2 - 1.4", contains: []string{"1.2.0-alpha", "1.4.9-rc.1"}, excludes: []string{"1.5.0-alpha"}},
+	} {
+		r, err := ParseRangeIncludePrerelease(test.r)
+		if !assert.Nil(t, err, "%q should parse", test.r) {
+			continue
+		}
+
+		for _, version := range test.contains {
+			assert.True(t, r.Contains(mustParse(t, version)), "%q should contain %s", test.r, version)
+		}
+		for _, version := range test.excludes {
+			assert.False(t, r.Contains(mustParse(t, version)), "%q should exclude %s", test.r, version)
+		}
+	}
+}
+
+func mustParse(t *testing.T, version string) *Version {
+	t.Helper()
+
+	v, err := Parse(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
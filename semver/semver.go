@@ -0,0 +1,134 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+// Package semver parses and compares semantic versions as used by npm.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+}
+
+// versionRegexp is the regular expression from https://semver.org.
+var versionRegexp = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// Parse parses a version strictly following the semver specification, so a
+// leading "v" or surrounding whitespace are rejected.
+func Parse(version string) (*Version, error) {
+	match := versionRegexp.FindStringSubmatch(version)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", version)
+	}
+
+	v := &Version{}
+	for i, field := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.ParseUint(match[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid semantic version: %w", version, err)
+		}
+		*field = n
+	}
+
+	if match[4] != "" {
+		v.Prerelease = strings.Split(match[4], ".")
+	}
+	if match[5] != "" {
+		v.Build = strings.Split(match[5], ".")
+	}
+
+	return v, nil
+}
+
+// String returns the canonical form of the version.
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+
+	return s
+}
+
+// IsPrerelease reports whether the version has prerelease identifiers.
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 depending on whether a is lower than, equal to or
+// greater than b. Build metadata is ignored as required by the specification.
+func Compare(a, b *Version) int {
+	for _, pair := range [][2]uint64{
+		{a.Major, b.Major},
+		{a.Minor, b.Minor},
+		{a.Patch, b.Patch},
+	} {
+		if c := compareUint(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+
+	// a version without prerelease identifiers has a higher precedence
+	switch {
+	case len(a.Prerelease) == 0 && len(b.Prerelease) == 0:
+		return 0
+	case len(a.Prerelease) == 0:
+		return 1
+	case len(b.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.Prerelease) && i < len(b.Prerelease); i++ {
+		if c := compareIdentifier(a.Prerelease[i], b.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareUint(uint64(len(a.Prerelease)), uint64(len(b.Prerelease)))
+}
+
+// compareIdentifier compares prerelease identifiers, numeric identifiers have
+// a lower precedence than alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
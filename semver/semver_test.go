@@ -0,0 +1,71 @@
+// Copyright (c) 2020, the Drone Plugins project authors.
+// Please see the AUTHORS file for details. All rights reserved.
+// Use of this source code is governed by an Apache 2.0 license that can be
+// found in the LICENSE file.
+
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("1.2.3-beta.1+build.5")
+	if assert.Nil(t, err) {
+		assert.Equal(t, uint64(1), v.Major)
+		assert.Equal(t, uint64(2), v.Minor)
+		assert.Equal(t, uint64(3), v.Patch)
+		assert.Equal(t, []string{"beta", "1"}, v.Prerelease)
+		assert.Equal(t, []string{"build", "5"}, v.Build)
+		assert.True(t, v.IsPrerelease())
+		assert.Equal(t, "1.2.3-beta.1+build.5", v.String())
+	}
+
+	v, err = Parse("0.0.0")
+	if assert.Nil(t, err) {
+		assert.False(t, v.IsPrerelease())
+	}
+
+	for _, invalid := range []string{"", "1", "1.2", "v1.2.3", " 1.2.3", "01.2.3", "1.2.3-", "1.2.3-01", "1.2.3+", "1.2.3.4", "latest"} {
+		_, err := Parse(invalid)
+		assert.NotNil(t, err, "%q should not parse", invalid)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// ordered by precedence, taken from the semver specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := Parse(ordered[i])
+			b, _ := Parse(ordered[j])
+
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			assert.Equal(t, expected, Compare(a, b), "%s compared to %s", ordered[i], ordered[j])
+		}
+	}
+
+	a, _ := Parse("1.0.0+build.1")
+	b, _ := Parse("1.0.0+build.2")
+	assert.Equal(t, 0, Compare(a, b))
+}